@@ -0,0 +1,100 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Options configures a call to Assemble
+type Options struct {
+	// Filename labels diagnostics, and files named by .include directives
+	// are looked up relative to its directory; it is not opened
+	Filename string
+	// IncludePaths are further directories to search for included files
+	IncludePaths []string
+	// Format selects the file format of the output; the default is .hack text
+	Format Format
+	// Listing, if set, receives a listing of every source line with the ROM
+	// address and encoding it assembled to, followed by the symbol table
+	Listing io.Writer
+	// DebugInfo, if set, receives JSON debug info mapping each ROM address back
+	// to its source position; see DebugInfo for the schema
+	DebugInfo io.Writer
+	// Object writes a relocatable object file to be combined by Link, instead
+	// of a ROM image; see Object for the schema. Format is ignored.
+	Object bool
+	// Data selects how the initial values of .word and .string directives
+	// are loaded; the default is a prologue of code at the start of ROM
+	Data DataStrategy
+	// RAMImage, if set, receives the initial contents of RAM in the format
+	// selected by Format when Data is DataImage
+	RAMImage io.Writer
+	// ExpandNegatives replaces each negative literal such as @-5 with the
+	// two instructions @4 and A=!A, which leave -5 in A, instead of
+	// reporting it as an error
+	ExpandNegatives bool
+	// RAMLimits are the RAM addresses that variables should not reach; if
+	// nil, DefaultRAMLimits are used
+	RAMLimits []RAMLimit
+	// AllowShadowing reports labels that redefine another label or a
+	// predefined symbol as warnings rather than errors; the last definition
+	// of a label is the one used
+	AllowShadowing bool
+	// StrictLabelUse reports a label that is used as a variable before the
+	// line that defines it as an error rather than a warning
+	StrictLabelUse bool
+}
+
+// Result describes a successful (or partially successful) assembly
+type Result struct {
+	// Instructions is the number of words written to the ROM image
+	Instructions int
+	// Symbols holds the predefined symbols, labels and variables
+	Symbols *SymbolTable
+	// Diagnostics holds every problem found in the source
+	Diagnostics *Diagnostics
+	// Usage summarizes the ROM and RAM the program takes up
+	Usage Usage
+}
+
+// AssemblyError is returned by Assemble when the source contains errors
+type AssemblyError struct {
+	File        string
+	Diagnostics []Diagnostic
+}
+
+func (e *AssemblyError) Error() string {
+	ds := Diagnostics{e.Diagnostics}
+	return fmt.Sprintf("%d error(s) found in %s", ds.ErrorCount(), e.File)
+}
+
+// Print writes the diagnostics to w as Diagnostics.Print does
+func (e *AssemblyError) Print(w io.Writer) {
+	ds := Diagnostics{e.Diagnostics}
+	ds.Print(w)
+}
+
+// Assemble reads Hack assembly from r and writes the ROM image to w in the
+// format selected by opts.Format.
+// The source is read once and both passes run in memory. If the source
+// contains errors nothing is written to w, and the returned error is an
+// *AssemblyError listing them; the Result is returned in either case.
+func Assemble(r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	asm := newAssembly(opts)
+	err = asm.convertSource(src, w)
+	return asm.result(), err
+}
+
+// AssembleFiles assembles several source files into a single ROM image, as if
+// they were concatenated in the order given. The files share a symbol table,
+// and diagnostics name the file each problem was found in.
+func AssembleFiles(paths []string, w io.Writer, opts Options) (*Result, error) {
+	asm := newAssembly(opts)
+	err := asm.convertFiles(paths, w)
+	return asm.result(), err
+}
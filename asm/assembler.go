@@ -0,0 +1,401 @@
+package asm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Assembler is the main object that converts Hack assembly source into binary
+// .hack text. It is safe for concurrent use: each conversion has its own
+// symbol table and diagnostics.
+type Assembler struct {
+	opts Options
+	mu   *sync.Mutex
+	last *assembly
+}
+
+// assembly holds the state of a single conversion
+type assembly struct {
+	opts    Options
+	encoder Code
+	st      SymbolTable
+	out     OutputWriter
+	listing *listing
+	debug   *DebugInfo
+	obj     *Object
+	defs    map[string]labelDef
+	pending *labelUse
+	data    []dataBlock
+	limits  []RAMLimit
+	diags   Diagnostics
+	count   int
+}
+
+// NewAssembler is a factory that creates an assembler using the built-in symbols
+func NewAssembler(opts Options) Assembler {
+	return Assembler{opts, &sync.Mutex{}, newAssembly(opts)}
+}
+
+// newAssembly starts a conversion with only the built-in symbols
+func newAssembly(opts Options) *assembly {
+	return &assembly{opts, Code{}, InitializeSymbolTable(), nil, nil, nil, nil, map[string]labelDef{}, nil, nil, ramLimits(opts), Diagnostics{}, 0}
+}
+
+// Diagnostics returns the problems found by the conversion that finished last
+func (a *Assembler) Diagnostics() *Diagnostics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &a.last.diags
+}
+
+// finish records a conversion as the last one
+func (a *Assembler) finish(run *assembly) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.last = run
+}
+
+// Convert is the main routine that assembles the source into w, and writes
+// a listing to Options.Listing and debug info to Options.DebugInfo if they
+// were requested.
+// Problems in the source are collected across the whole program rather than
+// stopping at the first one; if any errors were found, nothing is written to w
+// and an *AssemblyError is returned.
+func (a *Assembler) Convert(src []byte, w io.Writer) error {
+	run := newAssembly(a.opts)
+	defer a.finish(run)
+	return run.convertSource(src, w)
+}
+
+// ConvertFiles is like Convert, but reads the source from several files that
+// are assembled one after the other into a single program. The path
+// StdinPath reads standard input, which may be a pipe, as it is read once.
+func (a *Assembler) ConvertFiles(paths []string, w io.Writer) error {
+	run := newAssembly(a.opts)
+	defer a.finish(run)
+	return run.convertFiles(paths, w)
+}
+
+func (asm *assembly) convertSource(src []byte, w io.Writer) error {
+	lines, err := splitLines(src, asm.opts.Filename)
+	if err != nil {
+		return err
+	}
+	inc := newIncluder(asm.opts.IncludePaths, &asm.diags)
+	return asm.convert(inc.expand(lines, asm.opts.Filename), w)
+}
+
+func (asm *assembly) convertFiles(paths []string, w io.Writer) error {
+	if asm.opts.Filename == "" {
+		var names []string
+		for _, path := range paths {
+			names = append(names, sourceName(path))
+		}
+		asm.opts.Filename = strings.Join(names, ", ")
+	}
+	inc := newIncluder(asm.opts.IncludePaths, &asm.diags)
+	var lines []SourceLine
+	for _, path := range paths {
+		fileLines, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		path = sourceName(path)
+		if len(paths) > 1 {
+			for i := range fileLines {
+				fileLines[i].Module = path
+			}
+		}
+		lines = append(lines, inc.expand(fileLines, path)...)
+	}
+	return asm.convert(lines, w)
+}
+
+// result summarizes the conversion
+func (asm *assembly) result() *Result {
+	return &Result{asm.count, &asm.st, &asm.diags, newUsage(asm.count, &asm.st)}
+}
+
+func (asm *assembly) convert(lines []SourceLine, w io.Writer) error {
+	var out, lst bytes.Buffer
+	asm.out = NewOutputWriter(asm.opts.Format, &out)
+	if asm.opts.Object {
+		asm.obj = newObject(asm.opts.Filename)
+		asm.out = &objectWriter{asm.obj}
+	}
+	if asm.opts.Listing != nil {
+		asm.listing = newListing(&lst)
+	}
+	if asm.opts.DebugInfo != nil {
+		asm.debug = newDebugInfo()
+	}
+	lines = preprocess(lines, &asm.diags)
+	modules := findModules(lines)
+	prog := asm.buildSymbolTable(lines, modules)
+	var init []instruction
+	if asm.opts.Data == DataPrologue {
+		init = parseLines(prologue(asm.data), asm.opts.Filename, &asm.st)
+		asm.st.shiftLabels(len(init))
+	}
+	asm.translateInstructions(init, prog, modules)
+
+	if asm.diags.HasErrors() {
+		return &AssemblyError{asm.opts.Filename, asm.diags.Items()}
+	}
+	if asm.listing != nil {
+		if err := asm.listing.writeSymbols(&asm.st); err != nil {
+			return err
+		}
+		if _, err := lst.WriteTo(asm.opts.Listing); err != nil {
+			return err
+		}
+	}
+	if asm.debug != nil {
+		asm.debug.addSymbols(&asm.st)
+		if err := asm.debug.Write(asm.opts.DebugInfo); err != nil {
+			return err
+		}
+	}
+	if asm.obj != nil {
+		asm.obj.addSymbols(&asm.st)
+		return asm.obj.Write(w)
+	}
+	if asm.opts.Data == DataImage && asm.opts.RAMImage != nil {
+		if err := writeRAMImage(asm.data, asm.opts.Format, asm.opts.RAMImage); err != nil {
+			return err
+		}
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
+func (asm *assembly) report(ins *instruction, column int, length int, code string, format string, args ...interface{}) {
+	asm.diags.Add(ins.newDiagnostic(SeverityError, column, length, code, fmt.Sprintf(format, args...)))
+}
+
+// Perform a first pass of the input file, parsing every line and
+// constructing the symbol table that will be used in translating the
+// assembly code into binary.
+// For each label that is encountered, store the label in the table;
+// for each A or C instruction, increment the RAM address that is used
+// to store the next label. The parsed lines are returned for the second pass.
+func (asm *assembly) buildSymbolTable(lines []SourceLine, modules map[string]*module) []instruction {
+	p := newLineParser(lines, asm.opts.Filename, &asm.st, modules)
+	p.expandNegatives = asm.opts.ExpandNegatives
+	prog := make([]instruction, 0, len(lines))
+	addr := 0
+	for {
+		p.Advance(true)
+		if !p.HasMoreCommands() {
+			break
+		}
+		i := len(prog)
+		prog = append(prog, p.instruction(i))
+		if p.inverted {
+			// A negative literal such as @-5 is loaded as @4 and inverted
+			prog = append(prog, instruction{p.source(), i + 1, Command{C, CompNegA, JmpNull, LocA, ""}, nil, nil, nil, 0, nil, resolution{}, true})
+			addr++
+		}
+		ins := &prog[i]
+		// Syntax errors are reported by the second pass, together with
+		// the references that cannot be resolved
+		if ins.diag != nil {
+			continue
+		}
+		ctype := ins.cmd.ctype
+		if ctype == L {
+			sym := ins.cmd.symbol
+			if asm.st.Kind(sym) == SymExtern {
+				asm.report(ins, 1, len(ins.src.Text), CodeDuplicateSymbol, "%s is declared as extern and cannot be defined here", sym)
+				continue
+			}
+			if asm.defineLabel(ins, sym) {
+				asm.st.AddElement(sym, addr)
+			}
+		} else if ctype == Reserve || ctype == Data {
+			asm.defineData(ins)
+			asm.checkRAM(ins)
+		} else if ctype == Equ || ctype == Set {
+			if sym := ins.cmd.symbol; asm.defineConstant(ins, sym) {
+				asm.st.AddConstant(sym, ins.value)
+			}
+		} else if ctype == Extern && asm.obj != nil {
+			asm.declareExtern(ins)
+		} else if ctype == C || ctype == A {
+			addr++
+		}
+	}
+	return prog
+}
+
+// parseLines parses lines that do not define any symbols, such as the
+// instructions generated to initialize data
+func parseLines(lines []SourceLine, file string, st *SymbolTable) []instruction {
+	p := newLineParser(lines, file, st, nil)
+	var prog []instruction
+	for p.Advance(true); p.HasMoreCommands(); p.Advance(true) {
+		prog = append(prog, p.instruction(len(prog)))
+	}
+	return prog
+}
+
+// declareExtern adds a symbol named by .extern to the symbol table, so that
+// it is neither allocated as a variable nor encoded until link time
+func (asm *assembly) declareExtern(ins *instruction) {
+	sym := ins.cmd.symbol
+	col := strings.Index(ins.src.Text, sym) + 1
+	switch asm.st.Kind(sym) {
+	case SymPredefined:
+		asm.report(ins, col, len(sym), CodeDuplicateSymbol, "%s is a predefined symbol and cannot be extern", sym)
+	case SymLabel:
+		asm.report(ins, col, len(sym), CodeDuplicateSymbol, "%s is defined as a label and cannot be extern", sym)
+	default:
+		asm.st.AddExtern(sym)
+	}
+}
+
+// exportSymbol records a symbol named by .global. A symbol that is not a
+// label is allocated as a variable, so that objects can share data.
+func (asm *assembly) exportSymbol(ins *instruction) {
+	sym := ins.cmd.symbol
+	col := strings.Index(ins.src.Text, sym) + 1
+	switch asm.st.Kind(sym) {
+	case SymPredefined, SymExtern:
+		asm.report(ins, col, len(sym), CodeUndefinedSymbol, "%s is not defined in this file and cannot be exported", sym)
+		return
+	case SymConstant:
+		asm.report(ins, col, len(sym), CodeUndefinedSymbol, "%s is a constant and cannot be exported; define it in an included file instead", sym)
+		return
+	case SymNull:
+		asm.st.AddElement(sym, -1)
+	}
+	asm.obj.Exports = append(asm.obj.Exports, Export{sym, ins.src.File, ins.src.Line, col})
+}
+
+// Perform a second pass over the parsed lines, during which symbols are
+// resolved and the actual conversion to binary and writing of the output
+// is performed. The instructions that initialize data are written first.
+func (asm *assembly) translateInstructions(init []instruction, prog []instruction, modules map[string]*module) {
+	s := newScope(modules)
+	asm.translateLines(init, s)
+	asm.translateLines(prog, s)
+	if err := asm.out.Close(); err != nil {
+		line := 0
+		if len(prog) > 0 {
+			line = prog[len(prog)-1].src.Line
+		}
+		asm.diags.Add(Diagnostic{asm.opts.Filename, line, 0, 0, SeverityError, CodeInternal,
+			fmt.Sprintf("Unable to write output: %s", err), "", nil})
+	}
+}
+
+func (asm *assembly) translateLines(prog []instruction, s *scope) {
+	for i := range prog {
+		ins := &prog[i]
+		if ins.diag == nil && ins.operand != nil {
+			asm.resolve(ins, s)
+		}
+		if ins.diag != nil {
+			if ref := ins.undefined; ref != nil && asm.st.Kind(ref.qualified) == SymConstant {
+				ins.diag.Message = fmt.Sprintf("%s is not defined until a later line", ref.name)
+			}
+			asm.diags.Add(*ins.diag)
+		}
+		ctype := ins.cmd.ctype
+		addr, word, encoded := asm.count, uint16(0), false
+		if ctype.IsPrintable() {
+			asm.checkROM(ins)
+			asm.checkLabelUse(ins)
+			word, encoded = asm.processCommand(ins)
+			if asm.debug != nil {
+				asm.debug.addInstruction(ins, addr)
+			}
+			asm.count++
+		} else if ctype == Set {
+			// Give the instructions that follow the value set on this line,
+			// rather than the last value set in the first pass
+			if sym := ins.cmd.symbol; asm.defs[sym].redefinable {
+				asm.st.AddConstant(sym, ins.value)
+			}
+		} else if ctype == Global && asm.obj != nil {
+			asm.exportSymbol(ins)
+		} else if ctype == Extern && asm.obj == nil && len(s.modules) > 0 {
+			if sym := ins.cmd.symbol; !s.isExported(sym) {
+				asm.report(ins, strings.Index(ins.src.Text, sym)+1, len(sym), CodeUndefinedSymbol, "%s is not exported by any file", sym)
+			}
+		} else if ctype == Extern && asm.obj == nil {
+			// Without a link step, nothing but the program itself can define the symbol
+			if sym := ins.cmd.symbol; asm.st.Kind(sym) != SymLabel {
+				asm.report(ins, strings.Index(ins.src.Text, sym)+1, len(sym), CodeUndefinedSymbol,
+					"%s is declared with %s but not defined; externs are only resolved when object files are linked", sym, ExternToken)
+			}
+		}
+		asm.checkRAM(ins)
+		if asm.listing != nil {
+			asm.listing.writeLine(ins, &asm.st, addr, word, encoded)
+		}
+	}
+}
+
+// processCommand writes the binary encoding of the current command and returns it.
+// The boolean result is false if the command could not be encoded.
+func (asm *assembly) processCommand(ins *instruction) (uint16, bool) {
+	if ins.cmd.ctype == A {
+		return asm.writeACommand(ins)
+	}
+	if ins.cmd.ctype == C {
+		return asm.writeWord(ins, asm.encoder.Encode(ins.cmd.comp, ins.cmd.mloc, ins.cmd.jump))
+	}
+	return 0, false
+}
+
+func (asm *assembly) writeACommand(ins *instruction) (uint16, bool) {
+	n, err := strconv.ParseUint(ins.cmd.symbol, 10, 15)
+	if err != nil {
+		operand := strings.TrimPrefix(stripInlineComments(ins.src.Text), ACmdToken)
+		col := strings.Index(ins.src.Text, ACmdToken) + 2
+		if errors.Is(err, strconv.ErrRange) {
+			asm.report(ins, col, len(operand), CodeInvalidConstant, "%s does not fit in 15 bits", operand)
+		} else {
+			asm.report(ins, col, len(operand), CodeInvalidSymbol, "%s is not a valid symbol or decimal constant", operand)
+		}
+		return 0, false
+	}
+	if asm.obj != nil {
+		asm.addFixup(ins)
+	}
+	return asm.writeWord(ins, uint16(n))
+}
+
+// addFixup records that the current A-instruction loads a symbol whose
+// address may change when the object is linked
+func (asm *assembly) addFixup(ins *instruction) {
+	col := strings.Index(ins.src.Text, ACmdToken) + 2
+	if ins.res.mixed {
+		operand := strings.TrimPrefix(stripInlineComments(ins.src.Text), ACmdToken)
+		asm.report(ins, col, len(operand), CodeInvalidExpression,
+			"%s cannot be relocated; only a constant can be added to or subtracted from a label or variable", operand)
+		return
+	}
+	switch asm.st.Kind(ins.res.sym) {
+	case SymLabel, SymVariable, SymExtern:
+		asm.obj.Fixups = append(asm.obj.Fixups, Fixup{asm.count, ins.res.sym, ins.res.addend, ins.src.File, ins.src.Line, col})
+	}
+}
+
+// writeWord writes the encoding of the current command to the output
+func (asm *assembly) writeWord(ins *instruction, word uint16) (uint16, bool) {
+	log.Debugf("%016b", word)
+	if err := asm.out.WriteWord(word); err != nil {
+		asm.report(ins, 1, 0, CodeInternal, "Unable to write line %d: %s", ins.src.Line, err)
+		return 0, false
+	}
+	return word, true
+}
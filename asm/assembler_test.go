@@ -0,0 +1,18 @@
+package asm
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestAssembler(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("C Statement encoding", func() {
+		g.It("Should encode a C Statement with both a dest and a jump", func() {
+			out, _, err := assembleSource("AM=D-1;JGE")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal("1110001110101011\n")
+		})
+	})
+}
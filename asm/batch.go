@@ -0,0 +1,88 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BatchJob is a source file to assemble on its own as part of a batch. Out
+// and the writers in Options receive its outputs.
+type BatchJob struct {
+	Path    string
+	Out     io.Writer
+	Options Options
+}
+
+// BatchResult is the outcome of a single BatchJob
+type BatchResult struct {
+	Path   string
+	Result *Result
+	Err    error
+}
+
+// AssembleBatch assembles each job as a separate program, with up to workers
+// jobs running at a time. Every job has its own symbol table, so no state is
+// shared between them; the writers of different jobs must not be the same.
+// The results are in the same order as the jobs.
+func AssembleBatch(jobs []BatchJob, workers int) []BatchResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]BatchResult, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				job := jobs[i]
+				res, err := AssembleFiles([]string{job.Path}, job.Out, job.Options)
+				results[i] = BatchResult{job.Path, res, err}
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// BatchSummary counts the files of a batch that failed and the problems
+// found in all of them
+type BatchSummary struct {
+	Files    int
+	Failed   int
+	Errors   int
+	Warnings int
+}
+
+// SummarizeBatch totals the results of AssembleBatch
+func SummarizeBatch(results []BatchResult) BatchSummary {
+	s := BatchSummary{Files: len(results)}
+	for _, r := range results {
+		if r.Err != nil {
+			s.Failed++
+		}
+		if r.Result == nil {
+			continue
+		}
+		for _, d := range r.Result.Diagnostics.Items() {
+			switch d.Severity {
+			case SeverityError:
+				s.Errors++
+			case SeverityWarning:
+				s.Warnings++
+			}
+		}
+	}
+	return s
+}
+
+func (s BatchSummary) String() string {
+	return fmt.Sprintf("%d of %d file(s) assembled, %d failed: %d error(s), %d warning(s)",
+		s.Files-s.Failed, s.Files, s.Failed, s.Errors, s.Warnings)
+}
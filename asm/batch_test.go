@@ -0,0 +1,86 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestBatch(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Batch assembly", func() {
+		g.It("Should assemble each file on its own, in parallel", func() {
+			names := []string{"Max", "Pong", "Rect", "MaxL", "PongL", "RectL", "Add"}
+			var jobs []BatchJob
+			outs := make([]bytes.Buffer, len(names))
+			for i, name := range names {
+				jobs = append(jobs, BatchJob{"../test/" + name + ".asm", &outs[i], Options{}})
+			}
+			results := AssembleBatch(jobs, 4)
+			g.Assert(len(results)).Equal(len(names))
+			for i, name := range names {
+				g.Assert(results[i].Path).Equal("../test/" + name + ".asm")
+				g.Assert(results[i].Err == nil).IsTrue()
+				expected, _ := ioutil.ReadFile("../test/" + name + "Expected.hack")
+				g.Assert(outs[i].String()).Equal(string(expected))
+			}
+		})
+		g.It("Should summarize failures and diagnostics", func() {
+			var a, b bytes.Buffer
+			jobs := []BatchJob{
+				{"../test/Errors.asm", &a, Options{}},
+				{"../test/Add.asm", &b, Options{}},
+			}
+			results := AssembleBatch(jobs, 0)
+			g.Assert(results[0].Err == nil).IsFalse()
+			g.Assert(a.Len()).Equal(0)
+			s := SummarizeBatch(results)
+			g.Assert(s).Equal(BatchSummary{2, 1, 5, 0})
+			g.Assert(s.String()).Equal("1 of 2 file(s) assembled, 1 failed: 5 error(s), 0 warning(s)")
+		})
+		g.It("Should start each conversion with a fresh symbol table", func() {
+			var out bytes.Buffer
+			asm := NewAssembler(Options{Filename: "Prog.asm"})
+			g.Assert(asm.Convert([]byte("(L)\n@x"), &out) == nil).IsTrue()
+			g.Assert(asm.Convert([]byte("@y\n(L)"), &out) == nil).IsTrue()
+			g.Assert(asm.last.st.GetAddress("y")).Equal(16)
+			g.Assert(asm.last.st.Contains("x")).IsFalse()
+			g.Assert(asm.last.st.GetAddress("L")).Equal(1)
+		})
+		g.It("Should convert on several goroutines at once", func() {
+			asm := NewAssembler(Options{Filename: "Prog.asm"})
+			outs := make([]bytes.Buffer, 8)
+			errs := make([]error, len(outs))
+			var wg sync.WaitGroup
+			for i := range outs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = asm.Convert([]byte(fmt.Sprintf("@v%d\n@w\n(L)\n@%d", i, i)), &outs[i])
+				}(i)
+			}
+			wg.Wait()
+			for i := range outs {
+				expected, _, _ := assembleSource("@16", "@17", fmt.Sprintf("@%d", i))
+				g.Assert(errs[i] == nil).IsTrue()
+				g.Assert(outs[i].String()).Equal(expected)
+			}
+			g.Assert(len(asm.Diagnostics().Items())).Equal(0)
+		})
+		g.It("Should check RAM limits on each conversion", func() {
+			var out bytes.Buffer
+			src := []byte(".data buf 240\n    @x")
+			asm := NewAssembler(Options{Filename: "Prog.asm"})
+			for i := 0; i < 2; i++ {
+				g.Assert(asm.Convert(src, &out) == nil).IsTrue()
+				items := asm.Diagnostics().Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Message).Equal("x takes RAM[256], past the start of the stack at RAM[256]")
+			}
+		})
+	})
+}
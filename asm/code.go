@@ -0,0 +1,83 @@
+package asm
+
+// Code converts machine language tokens into binary
+type Code struct{}
+
+// cPrefix sets the three high bits that mark a word as a C instruction
+const cPrefix = 0xe000
+
+// compCodes holds the 7-bit a-c1..c6 field of each CompMnemonic
+var compCodes = [...]uint16{
+	Comp0:       0x2a,
+	Comp1:       0x3f,
+	CompMinus1:  0x3a,
+	CompD:       0x0c,
+	CompA:       0x30,
+	CompNegD:    0x0d,
+	CompNegA:    0x31,
+	CompMinusD:  0x0f,
+	CompMinusA:  0x33,
+	CompDplus1:  0x1f,
+	CompAplus1:  0x37,
+	CompDminus1: 0x0e,
+	CompAminus1: 0x32,
+	CompDplusA:  0x02,
+	CompDminusA: 0x13,
+	CompAminusD: 0x07,
+	CompDandA:   0x00,
+	CompDorA:    0x15,
+	CompM:       0x70,
+	CompNegM:    0x71,
+	CompMinusM:  0x73,
+	CompMplus1:  0x77,
+	CompMminus1: 0x72,
+	CompDplusM:  0x42,
+	CompDminusM: 0x53,
+	CompMminusD: 0x47,
+	CompDandM:   0x40,
+	CompDorM:    0x55,
+}
+
+// destCodes holds the 3-bit d1 d2 d3 field, which selects A, D and M, of each MemoryLocation
+var destCodes = [...]uint16{
+	LocNull: 0x0,
+	LocM:    0x1,
+	LocD:    0x2,
+	LocMD:   0x3,
+	LocA:    0x4,
+	LocAM:   0x5,
+	LocAD:   0x6,
+	LocAMD:  0x7,
+}
+
+// jumpCodes holds the 3-bit j1 j2 j3 field, which selects <0, =0 and >0, of each JumpMnemonic
+var jumpCodes = [...]uint16{
+	JmpNull: 0x0,
+	JGT:     0x1,
+	JEQ:     0x2,
+	JGE:     0x3,
+	JLT:     0x4,
+	JNE:     0x5,
+	JLE:     0x6,
+	JMP:     0x7,
+}
+
+// Dest converts a MemoryLocation mnemonic into its binary representation
+func (c *Code) Dest(mloc MemoryLocation) uint16 {
+	return destCodes[mloc]
+}
+
+// Jump converts a Jump mnemonic into its binary representation
+func (c *Code) Jump(jmp JumpMnemonic) uint16 {
+	return jumpCodes[jmp]
+}
+
+// Comp converts a Comp mnemonic into its binary representation
+func (c *Code) Comp(comp CompMnemonic) uint16 {
+	return compCodes[comp]
+}
+
+// Encode returns the 16-bit word of a C instruction
+func (c *Code) Encode(comp CompMnemonic, dest MemoryLocation, jmp JumpMnemonic) uint16 {
+	return cPrefix | c.Comp(comp)<<6 | c.Dest(dest)<<3 | c.Jump(jmp)
+}
@@ -1,4 +1,4 @@
-package main
+package asm
 
 import (
 	"fmt"
@@ -15,7 +15,7 @@ func TestCode(t *testing.T) {
 			m := map[string]string{}
 			for i, s := range CompStrings {
 				cmp := CompMnemonic(i)
-				bits := fmt.Sprintf("%v", c.Comp(cmp))
+				bits := fmt.Sprintf("%b", c.Comp(cmp))
 				if prev, exists := m[bits]; exists == true {
 					err := fmt.Sprintf("Same bit value found for %s and %s", s, prev)
 					g.Assert(false).IsTrue(err)
@@ -31,7 +31,7 @@ func TestCode(t *testing.T) {
 			m := map[string]string{}
 			for i, s := range MemoryLocationStrings {
 				mloc := MemoryLocation(i)
-				bits := fmt.Sprintf("%v", c.Dest(mloc))
+				bits := fmt.Sprintf("%b", c.Dest(mloc))
 				if prev, exists := m[bits]; exists == true {
 					err := fmt.Sprintf("Same bit value found for %s and %s", s, prev)
 					g.Assert(false).IsTrue(err)
@@ -47,7 +47,7 @@ func TestCode(t *testing.T) {
 			m := map[string]string{}
 			for i, s := range JumpStrings {
 				jmp := JumpMnemonic(i)
-				bits := fmt.Sprintf("%v", c.Jump(jmp))
+				bits := fmt.Sprintf("%b", c.Jump(jmp))
 				if prev, exists := m[bits]; exists == true {
 					err := fmt.Sprintf("Same bit value found for %s and %s", s, prev)
 					g.Assert(false).IsTrue(err)
@@ -56,4 +56,13 @@ func TestCode(t *testing.T) {
 			}
 		})
 	})
+
+	g.Describe("C instruction encoding", func() {
+		c := Code{}
+		g.It("Combines the fields into one word", func() {
+			g.Assert(fmt.Sprintf("%016b", c.Encode(CompDminusM, LocMD, JGT))).Equal("1111010011011001")
+			g.Assert(fmt.Sprintf("%016b", c.Encode(Comp0, LocNull, JMP))).Equal("1110101010000111")
+			g.Assert(fmt.Sprintf("%016b", c.Encode(CompDorA, LocAMD, JmpNull))).Equal("1110010101111000")
+		})
+	})
 }
@@ -0,0 +1,155 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codes for conflicting symbol definitions
+const (
+	CodeDuplicateLabel  = "duplicate-label"
+	CodePredefinedLabel = "predefined-label"
+	CodeLabelAsVariable = "label-as-variable"
+)
+
+// labelDef is the line that defined a label or constant, and its position
+// among the lines. redefinable is set for constants defined with .set.
+type labelDef struct {
+	src         SourceLine
+	index       int
+	redefinable bool
+}
+
+// labelUse is an A-instruction that loads a label defined further on
+type labelUse struct {
+	src SourceLine
+	sym string
+	def labelDef
+}
+
+// defineLabel checks that the label on the current line does not redefine
+// another label or a predefined symbol, and returns whether it should be
+// added to the symbol table. Redefinitions are errors unless shadowing is
+// allowed, in which case they are warnings and the last definition wins.
+func (asm *assembly) defineLabel(ins *instruction, sym string) bool {
+	col, length := labelColumn(ins.src.Text)
+	switch kind := asm.st.Kind(sym); kind {
+	case SymLabel, SymConstant, SymVariable:
+		msg := fmt.Sprintf("label %s is already defined", sym)
+		if kind != SymLabel {
+			msg += " as a " + SymbolKindStrings[kind]
+		}
+		asm.reportDuplicate(ins, col, length, sym, msg)
+	case SymPredefined:
+		asm.diags.Add(ins.newDiagnostic(asm.shadowingSeverity(), col, length, CodePredefinedLabel,
+			fmt.Sprintf("label %s redefines the predefined symbol %s = %d", sym, sym, asm.st.GetAddress(sym))))
+	default:
+		asm.defs[sym] = labelDef{ins.src, ins.index, false}
+		return true
+	}
+	if asm.opts.AllowShadowing {
+		asm.defs[sym] = labelDef{ins.src, ins.index, false}
+	}
+	return asm.opts.AllowShadowing
+}
+
+// defineConstant checks that the constant on the current line does not
+// redefine a label, a predefined symbol or a constant defined by .equ, and
+// returns whether it should be added to the symbol table. A constant defined
+// with .set may be redefined by another .set.
+func (asm *assembly) defineConstant(ins *instruction, sym string) bool {
+	col, length := labelColumn(ins.src.Text)
+	redefinable := ins.cmd.ctype == Set
+	switch asm.st.Kind(sym) {
+	case SymNull:
+	case SymConstant:
+		if !redefinable || !asm.defs[sym].redefinable {
+			asm.reportDuplicate(ins, col, length, sym, fmt.Sprintf("constant %s is already defined; only constants defined with %s can be redefined", sym, SetToken))
+			return false
+		}
+	case SymLabel, SymVariable:
+		asm.reportDuplicate(ins, col, length, sym, fmt.Sprintf("constant %s is already defined as a %s", sym, SymbolKindStrings[asm.st.Kind(sym)]))
+		return false
+	case SymPredefined:
+		asm.diags.Add(ins.newDiagnostic(SeverityError, col, length, CodePredefinedLabel,
+			fmt.Sprintf("constant %s redefines the predefined symbol %s = %d", sym, sym, asm.st.GetAddress(sym))))
+		return false
+	default:
+		asm.report(ins, col, length, CodeDuplicateSymbol, "%s is declared as extern and cannot be defined here", sym)
+		return false
+	}
+	asm.defs[sym] = labelDef{ins.src, ins.index, redefinable}
+	return true
+}
+
+// reportDuplicate reports a symbol that is already defined, with a note
+// pointing at its first definition
+func (asm *assembly) reportDuplicate(ins *instruction, col int, length int, sym string, msg string) {
+	sev := asm.shadowingSeverity()
+	if ins.cmd.ctype != L {
+		sev = SeverityError
+	}
+	d := ins.newDiagnostic(sev, col, length, CodeDuplicateLabel, msg)
+	first := asm.defs[sym].src
+	firstCol, firstLength := labelColumn(first.Text)
+	d.Notes = append(d.Notes, noteAt(first, firstCol, firstLength, fmt.Sprintf("%s was first defined here", sym))...)
+	asm.diags.Add(d)
+}
+
+// checkLabelUse reports a label that is loaded into A before the line that
+// defines it, and then used to read or write memory as if it were a
+// variable. Such code usually meant a variable of the same name, which the
+// later label definition silently replaced with a ROM address. It is a
+// warning unless StrictLabelUse is set, as the code may be deliberate.
+func (asm *assembly) checkLabelUse(ins *instruction) {
+	use := asm.pending
+	asm.pending = nil
+	cmd := ins.cmd
+	if cmd.ctype == A {
+		if def, ok := asm.defs[ins.res.sym]; ok && asm.st.Kind(ins.res.sym) == SymLabel && def.index > ins.index {
+			asm.pending = &labelUse{ins.src, ins.res.sym, def}
+		}
+		return
+	}
+	if use == nil || !accessesMemory(cmd) {
+		return
+	}
+	operand := strings.TrimPrefix(stripInlineComments(use.src.Text), ACmdToken)
+	sev := SeverityWarning
+	if asm.opts.StrictLabelUse {
+		sev = SeverityError
+	}
+	d := newLineDiagnostic(use.src, sev, strings.Index(use.src.Text, ACmdToken)+2, len(operand),
+		CodeLabelAsVariable, fmt.Sprintf("%s is used as a variable here, but is defined as a label later on", operand))
+	col, length := labelColumn(use.def.src.Text)
+	d.Notes = append(d.Notes, noteAt(use.def.src, col, length, fmt.Sprintf("%s is defined as a label here", operand))...)
+	asm.diags.Add(d)
+}
+
+func (asm *assembly) shadowingSeverity() Severity {
+	if asm.opts.AllowShadowing {
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// noteAt returns a note pointing at a line, followed by the notes for the
+// macro invocations the line was expanded from
+func noteAt(l SourceLine, column int, length int, msg string) []Diagnostic {
+	n := newLineDiagnostic(l, SeverityNote, column, length, "", msg)
+	notes := n.Notes
+	n.Notes = nil
+	return append([]Diagnostic{n}, notes...)
+}
+
+// labelColumn returns the position of the label definition on a line
+func labelColumn(text string) (int, int) {
+	code := stripInlineComments(text)
+	return strings.Index(text, code) + 1, len(code)
+}
+
+// accessesMemory returns whether a C instruction reads or writes M
+func accessesMemory(cmd Command) bool {
+	return cmd.ctype == C && (strings.Contains(MemoryLocationStrings[cmd.mloc], "M") ||
+		strings.Contains(CompStrings[cmd.comp], "M"))
+}
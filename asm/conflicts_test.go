@@ -0,0 +1,92 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestConflicts(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Label redefinitions", func() {
+		g.It("Should report both sites of a duplicate label", func() {
+			_, res, err := assembleSource(
+				"(LOOP)",
+				"    @LOOP",
+				"    0;JMP",
+				"  (LOOP) // again",
+			)
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			d := items[0]
+			g.Assert(d.Error()).Equal("Prog.asm:4:3: error: label LOOP is already defined [duplicate-label]")
+			g.Assert(d.Length).Equal(6)
+			g.Assert(len(d.Notes)).Equal(1)
+			g.Assert(d.Notes[0].Error()).Equal("Prog.asm:1:1: note: LOOP was first defined here")
+		})
+		g.It("Should report labels that redefine predefined symbols", func() {
+			_, res, _ := assembleSource("(SCREEN)", "(R3)", "(R16)")
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Code).Equal(CodePredefinedLabel)
+			g.Assert(items[0].Message).Equal("label SCREEN redefines the predefined symbol SCREEN = 16384")
+			g.Assert(items[1].Line).Equal(2)
+		})
+		g.It("Should point duplicates from macros at the invocation", func() {
+			_, res, _ := assembleSource(".macro SPIN", "(SPIN_HERE)", ".endm", "SPIN", "SPIN")
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(len(items[0].Notes)).Equal(3)
+			g.Assert(items[0].Notes[0].Line).Equal(5)
+			g.Assert(items[0].Notes[2].Line).Equal(4)
+		})
+	})
+
+	g.Describe("Labels used as variables", func() {
+		g.It("Should report a label used to access memory before its definition", func() {
+			_, res, _ := assembleSource(
+				"    @count",
+				"    M=0",
+				"(count)",
+				"    @count",
+				"    M=M+1",
+				"    @count",
+				"    0;JMP",
+			)
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Error()).Equal("Prog.asm:1:6: warning: count is used as a variable here, " +
+				"but is defined as a label later on [label-as-variable]")
+			g.Assert(items[0].Notes[0].Line).Equal(3)
+		})
+		g.It("Should fail on a label used as a variable when strict", func() {
+			var out bytes.Buffer
+			src := strings.Join([]string{"    @count", "    M=0", "(count)"}, "\n")
+			res, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", StrictLabelUse: true})
+			g.Assert(err == nil).IsFalse()
+			g.Assert(res.Diagnostics.Items()[0].Severity).Equal(SeverityError)
+		})
+		g.It("Should accept forward jumps", func() {
+			_, _, err := assembleSource("    @END", "    D;JGT", "    @END", "    0;JMP", "(END)")
+			g.Assert(err == nil).IsTrue()
+		})
+	})
+
+	g.Describe("Shadowing", func() {
+		g.It("Should downgrade conflicts to warnings when allowed", func() {
+			var out bytes.Buffer
+			src := strings.Join([]string{"(LOOP)", "    @LOOP", "(LOOP)", "(R3)", "    @R3"}, "\n")
+			res, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", AllowShadowing: true})
+			g.Assert(err == nil).IsTrue()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Severity).Equal(SeverityWarning)
+			g.Assert(items[1].Severity).Equal(SeverityWarning)
+			expected, _, _ := assembleSource("@1", "@1")
+			g.Assert(out.String()).Equal(expected)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestConstants(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Constant definitions", func() {
+		g.It("Should substitute constants without allocating RAM", func() {
+			out, res, err := assembleSource(
+				".equ ROWS 256",
+				".define WORDS, ROWS*32",
+				"    @SCREEN+WORDS-1",
+				"    @WORDS",
+				"    @x",
+			)
+			expected, _, _ := assembleSource("@24575", "@8192", "@16")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+			g.Assert(res.Symbols.Kind("ROWS")).Equal(SymConstant)
+			g.Assert(res.Symbols.NextRAM()).Equal(17)
+		})
+		g.It("Should use the value of the closest .set above", func() {
+			out, _, err := assembleSource(".set STEP 1", "    @STEP", ".set STEP STEP*2", "    @STEP")
+			expected, _, _ := assembleSource("@1", "@2")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+		g.It("Should list constants separately", func() {
+			var out, lst, dbg bytes.Buffer
+			src := ".equ ROWS 256\n    @ROWS\n(LOOP)\n    @n"
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", Listing: &lst, DebugInfo: &dbg})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(strings.Contains(lst.String(), "= 256        .equ ROWS 256")).IsTrue()
+			g.Assert(strings.HasSuffix(lst.String(), "\nConstants:\n  ROWS                             = 256\n")).IsTrue()
+			d, _ := LoadDebugInfo(&dbg)
+			value, ok := d.Constant("ROWS")
+			g.Assert(ok).IsTrue()
+			g.Assert(value).Equal(256)
+			_, ok = d.Variable("ROWS")
+			g.Assert(ok).IsFalse()
+		})
+	})
+
+	g.Describe("Constant errors", func() {
+		cases := []struct {
+			lines   []string
+			line    int
+			column  int
+			code    string
+			message string
+		}{
+			{[]string{".equ A 1", ".equ A 2"}, 2, 1, CodeDuplicateLabel,
+				"constant A is already defined; only constants defined with .set can be redefined"},
+			{[]string{".equ A 1", ".set A 2"}, 2, 1, CodeDuplicateLabel,
+				"constant A is already defined; only constants defined with .set can be redefined"},
+			{[]string{"(A)", ".equ A 1"}, 2, 1, CodeDuplicateLabel, "constant A is already defined as a label"},
+			{[]string{".equ A 1", "(A)"}, 2, 1, CodeDuplicateLabel, "label A is already defined as a constant"},
+			{[]string{".equ KBD 1"}, 1, 1, CodePredefinedLabel, "constant KBD redefines the predefined symbol KBD = 24576"},
+			{[]string{".equ A B+1", ".equ B 1"}, 1, 8, CodeUndefinedSymbol, "B is not defined until a later line"},
+			{[]string{".equ A  C"}, 1, 9, CodeUndefinedSymbol, "C is not defined"},
+			{[]string{"(L)", ".equ A L+1"}, 2, 8, CodeInvalidExpression, "L is a label, not a constant"},
+			{[]string{".equ A"}, 1, 1, CodeInvalidCommand, ".equ takes a name and a value"},
+			{[]string{".set N -1", "    @N"}, 2, 6, CodeInvalidConstant, "N = -1, which does not fit in 15 bits"},
+		}
+		for _, c := range cases {
+			c := c
+			g.It("Should report "+c.message, func() {
+				_, res, err := assembleSource(c.lines...)
+				g.Assert(err == nil).IsFalse()
+				items := res.Diagnostics.Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Line).Equal(c.line)
+				g.Assert(items[0].Column).Equal(c.column)
+				g.Assert(items[0].Code).Equal(c.code)
+				g.Assert(items[0].Message).Equal(c.message)
+			})
+		}
+	})
+}
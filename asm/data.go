@@ -0,0 +1,237 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CodeInvalidData identifies data directives that cannot be assembled
+const CodeInvalidData = "invalid-data"
+
+// DataStrategy is an integer enum type
+type DataStrategy int
+
+// Enum for the ways the initial values of data directives can be loaded:
+// DataPrologue emits code at the start of ROM that stores them in RAM
+// DataImage writes them to a separate RAM image, for emulators that can preload RAM
+const (
+	DataPrologue DataStrategy = iota
+	DataImage
+)
+
+// DataStrategyStrings enables converting a DataStrategy to and from its string representation
+var DataStrategyStrings = []string{"prologue", "image"}
+
+func (s DataStrategy) String() string {
+	return DataStrategyStrings[s]
+}
+
+// dataBlock is a RAM region declared by a data directive
+type dataBlock struct {
+	src         SourceLine
+	addr        int
+	words       []uint16
+	initialized bool
+}
+
+// parseData parses the directives that declare named RAM regions:
+//
+//	.data buffer 32           reserves 32 words
+//	.word table 1, 2, -1, 'A' stores a word for each value
+//	.string msg "Hi\n"        stores each character, followed by 0
+//
+// Sizes and values may be expressions of predefined symbols and constants.
+func (p *Parser) parseData(line string) (Command, error) {
+	token := strings.Fields(line)[0]
+	operand := map[string]string{DataToken: "a size", WordToken: "a list of values", StringToken: "a quoted string"}[token]
+	name, rest, err := splitDirective(line, token)
+	if err != nil {
+		return Command{}, err
+	}
+	if name == "" || rest == "" {
+		return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "%s takes a name and %s", token, operand)
+	}
+	offset := len(line) - len(rest)
+	var words []uint16
+	switch token {
+	case DataToken:
+		v, err := p.evalData(rest, offset)
+		if err != nil {
+			return Command{}, err
+		}
+		if v < 1 || v > 0x7fff {
+			return Command{}, newSyntaxError(offset+1, len(rest), CodeInvalidData, "%s evaluates to %d, which is not a valid size", rest, v)
+		}
+		words = make([]uint16, v)
+	case WordToken:
+		values, positions := splitValues(rest)
+		for i, value := range values {
+			if value == "" {
+				return Command{}, newSyntaxError(offset+positions[i]+1, 1, CodeInvalidExpression, "value is missing")
+			}
+			v, err := p.evalData(value, offset+positions[i])
+			if err != nil {
+				return Command{}, err
+			}
+			if v < -0x8000 || v > 0xffff {
+				return Command{}, newSyntaxError(offset+positions[i]+1, len(value), CodeInvalidConstant,
+					"%s evaluates to %d, which does not fit in 16 bits", value, v)
+			}
+			words = append(words, uint16(v))
+		}
+	case StringToken:
+		s, err := strconv.Unquote(rest)
+		if err != nil || rest[0] != '"' {
+			return Command{}, newSyntaxError(offset+1, len(rest), CodeInvalidExpression, "%s is not a valid string literal", rest)
+		}
+		for _, r := range s {
+			if r > 0x7fff {
+				return Command{}, newSyntaxError(offset+1, len(rest), CodeInvalidConstant, "%q does not fit in 15 bits", r)
+			}
+			words = append(words, uint16(r))
+		}
+		words = append(words, 0)
+	}
+	ctype := Data
+	if token == DataToken {
+		ctype = Reserve
+	}
+	p.words = words
+	return Command{ctype, Comp0, JmpNull, LocNull, p.scope.private(name, p.module)}, nil
+}
+
+// evalData evaluates an expression that starts at the given offset in the line
+func (p *Parser) evalData(expr string, offset int) (int, error) {
+	v, err := evalExpr(expr, p.constantLookup)
+	if se, ok := err.(*syntaxError); ok {
+		se.column += offset
+	}
+	return v.n, err
+}
+
+// splitValues splits a comma separated list of expressions, and returns the
+// offset of each one in s. Commas in character literals do not separate values.
+func splitValues(s string) ([]string, []int) {
+	var values []string
+	var positions []int
+	start, quoted := 0, false
+	for i := 0; i <= len(s); i++ {
+		switch {
+		case i < len(s) && s[i] == '\\' && quoted:
+			i++
+		case i < len(s) && s[i] == '\'':
+			quoted = !quoted
+		case i == len(s) || (s[i] == ',' && !quoted):
+			value := strings.TrimSpace(s[start:i])
+			pos := start + strings.Index(s[start:i], value)
+			if value == "" {
+				pos = i
+			}
+			values, positions = append(values, value), append(positions, pos)
+			start = i + 1
+		}
+	}
+	return values, positions
+}
+
+// defineData allocates the RAM region declared on the current line, after
+// checking that its name is not already in use
+func (asm *assembly) defineData(ins *instruction) {
+	sym := ins.cmd.symbol
+	col, length := labelColumn(ins.src.Text)
+	if asm.obj != nil {
+		asm.report(ins, col, length, CodeInvalidData, "data directives cannot be used in object files")
+		return
+	}
+	switch kind := asm.st.Kind(sym); kind {
+	case SymNull:
+	case SymPredefined:
+		asm.diags.Add(ins.newDiagnostic(SeverityError, col, length, CodePredefinedLabel,
+			fmt.Sprintf("data %s redefines the predefined symbol %s = %d", sym, sym, asm.st.GetAddress(sym))))
+		return
+	case SymExtern:
+		asm.report(ins, col, length, CodeDuplicateSymbol, "%s is declared as extern and cannot be defined here", sym)
+		return
+	case SymVariable:
+		asm.reportDuplicate(ins, col, length, sym, fmt.Sprintf("data %s is already defined", sym))
+		return
+	default:
+		asm.reportDuplicate(ins, col, length, sym, fmt.Sprintf("data %s is already defined as a %s", sym, SymbolKindStrings[kind]))
+		return
+	}
+	asm.defs[sym] = labelDef{ins.src, ins.index, false}
+	addr := asm.st.Allocate(sym, len(ins.words))
+	asm.data = append(asm.data, dataBlock{ins.src, addr, ins.words, ins.cmd.ctype == Data})
+}
+
+// prologue returns the instructions that store the initial values of the
+// data regions in RAM. Each instruction is attributed to the directive that
+// declared the value. D is reused when it already holds the value, or one
+// less or one more than it, so that runs of equal or consecutive values take
+// two instructions per word rather than four.
+func prologue(blocks []dataBlock) []SourceLine {
+	var lines []SourceLine
+	d, known := uint16(0), false
+	for _, b := range blocks {
+		if !b.initialized {
+			continue
+		}
+		indent := b.src.Text[:len(b.src.Text)-len(strings.TrimLeft(b.src.Text, " \t"))]
+		emit := func(texts ...string) {
+			for _, text := range texts {
+				l := b.src
+				l.Text = indent + text
+				lines = append(lines, l)
+			}
+		}
+		for i, w := range b.words {
+			addr := ACmdToken + strconv.Itoa(b.addr+i)
+			switch {
+			case w == 0 || w == 1:
+				emit(addr, "M="+strconv.Itoa(int(w)))
+			case w == 0xffff:
+				emit(addr, "M=-1")
+			case known && w == d:
+				emit(addr, "M=D")
+			case known && w == d+1:
+				emit(addr, "MD=D+1")
+				d = w
+			case known && w == d-1:
+				emit(addr, "MD=D-1")
+				d = w
+			case w <= 0x7fff:
+				emit(ACmdToken+strconv.Itoa(int(w)), "D=A", addr, "M=D")
+				d, known = w, true
+			default:
+				emit(ACmdToken+strconv.Itoa(int(^w)), "D=!A", addr, "M=D")
+				d, known = w, true
+			}
+		}
+	}
+	return lines
+}
+
+// writeRAMImage writes the initial contents of RAM from address 0 to the end
+// of the last data region, in the given format. Reserved regions and the
+// words that are not part of any region are 0.
+func writeRAMImage(blocks []dataBlock, f Format, w io.Writer) error {
+	end := 0
+	for _, b := range blocks {
+		if b.addr+len(b.words) > end {
+			end = b.addr + len(b.words)
+		}
+	}
+	ram := make([]uint16, end)
+	for _, b := range blocks {
+		copy(ram[b.addr:], b.words)
+	}
+	ow := NewOutputWriter(f, w)
+	for _, word := range ram {
+		if err := ow.WriteWord(word); err != nil {
+			return err
+		}
+	}
+	return ow.Close()
+}
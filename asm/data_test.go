@@ -0,0 +1,122 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestData(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Data directives", func() {
+		g.It("Should allocate regions before variables", func() {
+			_, res, err := assembleSource(
+				".equ N 4",
+				".data buf N*2",
+				".word table 1, 2",
+				".string msg \"ok\"",
+				"    @x",
+			)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(res.Symbols.GetAddress("buf")).Equal(16)
+			g.Assert(res.Symbols.GetAddress("table")).Equal(24)
+			g.Assert(res.Symbols.GetAddress("msg")).Equal(26)
+			g.Assert(res.Symbols.GetAddress("x")).Equal(29)
+		})
+		g.It("Should emit a prologue that reuses D", func() {
+			out, _, err := assembleSource(
+				".word table 7, 7, 8, 0, -1, -2",
+				"(START)",
+				"    @START",
+				"    0;JMP",
+			)
+			expected, _, _ := assembleSource(
+				"@7", "D=A", "@16", "M=D",
+				"@17", "M=D",
+				"@18", "MD=D+1",
+				"@19", "M=0",
+				"@20", "M=-1",
+				"@1", "D=!A", "@21", "M=D",
+				"@16", "0;JMP",
+			)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+		g.It("Should write a RAM image instead when asked", func() {
+			var out, ram bytes.Buffer
+			src := ".string msg \"A,\"\n.word w ',', 0x8000\n    @msg"
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", Data: DataImage, RAMImage: &ram})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out.String()).Equal("0000000000010000\n")
+			lines := strings.Split(strings.TrimSpace(ram.String()), "\n")
+			g.Assert(len(lines)).Equal(21)
+			g.Assert(lines[16]).Equal("0000000001000001")
+			g.Assert(lines[17]).Equal("0000000000101100")
+			g.Assert(lines[18]).Equal("0000000000000000")
+			g.Assert(lines[19]).Equal("0000000000101100")
+			g.Assert(lines[20]).Equal("1000000000000000")
+		})
+		g.It("Should not start a comment inside a literal", func() {
+			var out, ram bytes.Buffer
+			src := ".string url \"a//b\" // comment\n.word w '/', 1 // '\n    @url"
+			res, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", Data: DataImage, RAMImage: &ram})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(res.Symbols.GetAddress("w")).Equal(21)
+			lines := strings.Split(strings.TrimSpace(ram.String()), "\n")
+			g.Assert(lines[16:]).Equal([]string{"0000000001100001", "0000000000101111", "0000000000101111",
+				"0000000001100010", "0000000000000000", "0000000000101111", "0000000000000001"})
+		})
+		g.It("Should attribute the prologue to the directives", func() {
+			var out, dbg bytes.Buffer
+			src := "  .word w 5\n    @w"
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", DebugInfo: &dbg})
+			g.Assert(err == nil).IsTrue()
+			d, _ := LoadDebugInfo(&dbg)
+			g.Assert(len(d.Instructions)).Equal(5)
+			loc, _ := d.Lookup(3)
+			g.Assert(loc.Line).Equal(1)
+			g.Assert(loc.Column).Equal(3)
+			loc, _ = d.Lookup(4)
+			g.Assert(loc.Line).Equal(2)
+		})
+	})
+
+	g.Describe("Data errors", func() {
+		cases := []struct {
+			lines   []string
+			column  int
+			code    string
+			message string
+		}{
+			{[]string{".word t 1, 300*300"}, 12, CodeInvalidConstant, "300*300 evaluates to 90000, which does not fit in 16 bits"},
+			{[]string{".word t 1,, 2"}, 11, CodeInvalidExpression, "value is missing"},
+			{[]string{".word t X"}, 9, CodeUndefinedSymbol, "X is not defined"},
+			{[]string{".data buf 0"}, 11, CodeInvalidData, "0 evaluates to 0, which is not a valid size"},
+			{[]string{".string s 'x'"}, 11, CodeInvalidExpression, "'x' is not a valid string literal"},
+			{[]string{".data"}, 1, CodeInvalidCommand, ".data takes a name and a size"},
+			{[]string{".word t 1", ".data t 2"}, 1, CodeDuplicateLabel, "data t is already defined"},
+			{[]string{".equ t 1", ".data t 2"}, 1, CodeDuplicateLabel, "data t is already defined as a constant"},
+			{[]string{".data t 2", "(t)"}, 1, CodeDuplicateLabel, "label t is already defined as a variable"},
+			{[]string{".word SP 1"}, 1, CodePredefinedLabel, "data SP redefines the predefined symbol SP = 0"},
+		}
+		for _, c := range cases {
+			c := c
+			g.It("Should report "+c.message, func() {
+				_, res, err := assembleSource(c.lines...)
+				g.Assert(err == nil).IsFalse()
+				items := res.Diagnostics.Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Column).Equal(c.column)
+				g.Assert(items[0].Code).Equal(c.code)
+				g.Assert(items[0].Message).Equal(c.message)
+			})
+		}
+		g.It("Should reject data in object files", func() {
+			var out bytes.Buffer
+			res, _ := Assemble(strings.NewReader(".word t 1"), &out, Options{Filename: "Prog.asm", Object: true})
+			g.Assert(res.Diagnostics.Items()[0].Code).Equal(CodeInvalidData)
+		})
+	})
+}
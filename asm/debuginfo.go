@@ -0,0 +1,124 @@
+package asm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DebugInfoVersion is the version of the debug info schema written by the assembler
+const DebugInfoVersion = 1
+
+// DebugInfo maps ROM addresses back to the source they were assembled from, and
+// names to the addresses of labels and variables. It is written as JSON:
+//
+//	{
+//	  "version": 1,
+//	  "instructions": [
+//	    {"address": 0, "file": "Max.asm", "line": 8, "column": 4},
+//	    ...
+//	  ],
+//	  "labels": {"OUTPUT_FIRST": 10, ...},
+//	  "variables": {"counter": 16, ...},
+//	  "constants": {"ROWS": 256, ...}
+//	}
+//
+// instructions holds one entry per ROM word in address order, so that entry n
+// describes address n. Lines and columns are 1-based; the column is that of
+// the first character of the instruction. labels holds ROM addresses and
+// variables holds RAM addresses; constants hold the values named by .equ
+// and .set, and predefined symbols are not included.
+type DebugInfo struct {
+	Version      int              `json:"version"`
+	Instructions []SourceLocation `json:"instructions"`
+	Labels       map[string]int   `json:"labels"`
+	Variables    map[string]int   `json:"variables"`
+	Constants    map[string]int   `json:"constants"`
+	// lines holds the source line of each instruction, which is not written
+	lines []SourceLine
+}
+
+// SourceLocation is the position of the instruction stored at a ROM address
+type SourceLocation struct {
+	Address int    `json:"address"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func newDebugInfo() *DebugInfo {
+	return &DebugInfo{DebugInfoVersion, []SourceLocation{}, map[string]int{}, map[string]int{}, map[string]int{}, nil}
+}
+
+// LoadDebugInfo reads debug info written by the assembler
+func LoadDebugInfo(r io.Reader) (*DebugInfo, error) {
+	var d DebugInfo
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Version != DebugInfoVersion {
+		return nil, fmt.Errorf("unsupported debug info version %d", d.Version)
+	}
+	for i, loc := range d.Instructions {
+		if loc.Address != i {
+			return nil, fmt.Errorf("instruction %d has address %d", i, loc.Address)
+		}
+	}
+	return &d, nil
+}
+
+// Write encodes the debug info as indented JSON
+func (d *DebugInfo) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Lookup returns the source location of the instruction at a ROM address
+func (d *DebugInfo) Lookup(pc int) (SourceLocation, bool) {
+	if pc < 0 || pc >= len(d.Instructions) {
+		return SourceLocation{}, false
+	}
+	return d.Instructions[pc], true
+}
+
+// Variable returns the RAM address allocated to a variable
+func (d *DebugInfo) Variable(name string) (int, bool) {
+	addr, ok := d.Variables[name]
+	return addr, ok
+}
+
+// Label returns the ROM address that a label marks
+func (d *DebugInfo) Label(name string) (int, bool) {
+	addr, ok := d.Labels[name]
+	return addr, ok
+}
+
+// Constant returns the value of a constant defined with .equ or .set
+func (d *DebugInfo) Constant(name string) (int, bool) {
+	value, ok := d.Constants[name]
+	return value, ok
+}
+
+// addInstruction records the location of the instruction on the parser's current line
+func (d *DebugInfo) addInstruction(ins *instruction, addr int) {
+	col := 1
+	for col <= len(ins.src.Text) && (ins.src.Text[col-1] == ' ' || ins.src.Text[col-1] == '\t') {
+		col++
+	}
+	d.Instructions = append(d.Instructions, SourceLocation{addr, ins.src.File, ins.src.Line, col})
+	d.lines = append(d.lines, ins.src)
+}
+
+// addSymbols records the labels, variables and constants of the symbol table
+func (d *DebugInfo) addSymbols(st *SymbolTable) {
+	for _, sym := range st.Symbols(SymLabel) {
+		d.Labels[sym.Name] = sym.Address
+	}
+	for _, sym := range st.Symbols(SymVariable) {
+		d.Variables[sym.Name] = sym.Address
+	}
+	for _, sym := range st.Symbols(SymConstant) {
+		d.Constants[sym.Name] = sym.Address
+	}
+}
@@ -0,0 +1,54 @@
+package asm
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestDebugInfo(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Debug info", func() {
+		f, _ := os.Open("../test/Rect.asm")
+		defer f.Close()
+		var out, dbg bytes.Buffer
+		_, err := Assemble(f, &out, Options{Filename: "Rect.asm", DebugInfo: &dbg})
+
+		g.It("Should be emitted alongside the binary", func() {
+			g.Assert(err == nil).IsTrue()
+			g.Assert(dbg.Len() > 0).IsTrue()
+		})
+		g.It("Should round trip through the loader", func() {
+			d, err := LoadDebugInfo(bytes.NewReader(dbg.Bytes()))
+			g.Assert(err == nil).IsTrue()
+			g.Assert(d.Version).Equal(DebugInfoVersion)
+			g.Assert(len(d.Instructions)).Equal(strings.Count(out.String(), "\n"))
+		})
+		g.It("Should map a PC back to its source line", func() {
+			d, _ := LoadDebugInfo(bytes.NewReader(dbg.Bytes()))
+			loc, ok := d.Lookup(10)
+			g.Assert(ok).IsTrue()
+			g.Assert(loc).Equal(SourceLocation{10, "Rect.asm", 20, 4})
+			_, ok = d.Lookup(len(d.Instructions))
+			g.Assert(ok).IsFalse()
+		})
+		g.It("Should resolve labels and variables", func() {
+			d, _ := LoadDebugInfo(bytes.NewReader(dbg.Bytes()))
+			addr, ok := d.Label("LOOP")
+			g.Assert(ok).IsTrue()
+			g.Assert(addr).Equal(10)
+			addr, ok = d.Variable("address")
+			g.Assert(ok).IsTrue()
+			g.Assert(addr).Equal(17)
+			_, ok = d.Variable("SCREEN")
+			g.Assert(ok).IsFalse()
+		})
+		g.It("Should reject an unknown schema version", func() {
+			_, err := LoadDebugInfo(strings.NewReader(`{"version": 99}`))
+			g.Assert(err != nil).IsTrue()
+		})
+	})
+}
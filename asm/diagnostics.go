@@ -0,0 +1,155 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Severity is an integer enum type
+type Severity int
+
+// Enum for the possible severities of a diagnostic:
+// SeverityError prevents the output from being produced
+// SeverityWarning is reported but does not fail the run
+// SeverityNote adds context to another diagnostic
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+// SeverityStrings enables converting a Severity to and from its string representation
+var SeverityStrings = []string{"error", "warning", "note"}
+
+func (s Severity) String() string {
+	return SeverityStrings[s]
+}
+
+// Codes that identify each kind of diagnostic, so that tools can match on them
+// without parsing the message
+const (
+	CodeInvalidCommand  = "invalid-command"
+	CodeInvalidDest     = "invalid-dest"
+	CodeInvalidComp     = "invalid-comp"
+	CodeInvalidJump     = "invalid-jump"
+	CodeInvalidConstant = "invalid-constant"
+	CodeInvalidSymbol   = "invalid-symbol"
+	CodeInternal        = "internal"
+)
+
+// Diagnostic describes a problem found in the source, along with
+// the position at which it was found
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Length   int
+	Severity Severity
+	Code     string
+	Message  string
+	Source   string
+	// Notes give further context, such as the macro invocations that
+	// a line was expanded from
+	Notes []Diagnostic
+}
+
+// Error formats the diagnostic on a single line in the conventional
+// compiler style: file:line:col: severity: message [code]
+func (d Diagnostic) Error() string {
+	if d.Code == "" {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s [%s]", d.File, d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+// Excerpt returns the source line the diagnostic refers to, followed by
+// a line that underlines the offending characters with a caret
+func (d Diagnostic) Excerpt() string {
+	if d.Source == "" || d.Column < 1 {
+		return ""
+	}
+	// Keep tabs in the marker line so that it lines up with the source
+	var marker strings.Builder
+	for i, r := range d.Source {
+		if i >= d.Column-1 {
+			break
+		}
+		if r == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteRune(' ')
+		}
+	}
+	marker.WriteString("^")
+	if d.Length > 1 {
+		marker.WriteString(strings.Repeat("~", d.Length-1))
+	}
+	return fmt.Sprintf("%s\n%s", d.Source, marker.String())
+}
+
+// Diagnostics collects every diagnostic produced while processing a file,
+// so that they can all be reported at the end of the run
+type Diagnostics struct {
+	items []Diagnostic
+}
+
+// Add records a diagnostic
+func (ds *Diagnostics) Add(d Diagnostic) {
+	ds.items = append(ds.items, d)
+}
+
+// Items returns the recorded diagnostics in the order they were added
+func (ds *Diagnostics) Items() []Diagnostic {
+	return ds.items
+}
+
+// ErrorCount returns the number of diagnostics with error severity
+func (ds *Diagnostics) ErrorCount() int {
+	n := 0
+	for _, d := range ds.items {
+		if d.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors indicates whether any diagnostic with error severity was recorded
+func (ds *Diagnostics) HasErrors() bool {
+	return ds.ErrorCount() > 0
+}
+
+// Print writes every diagnostic to w, each followed by its source excerpt and its notes
+func (ds *Diagnostics) Print(w io.Writer) {
+	for _, d := range ds.items {
+		printDiagnostic(w, d)
+		for _, n := range d.Notes {
+			printDiagnostic(w, n)
+		}
+	}
+}
+
+func printDiagnostic(w io.Writer, d Diagnostic) {
+	fmt.Fprintln(w, d.Error())
+	if ex := d.Excerpt(); ex != "" {
+		fmt.Fprintln(w, ex)
+	}
+}
+
+// syntaxError is returned by the parsing routines to indicate where in the
+// line a problem was found. Columns are 1-based.
+type syntaxError struct {
+	column int
+	length int
+	code   string
+	msg    string
+}
+
+func (e *syntaxError) Error() string {
+	return e.msg
+}
+
+func newSyntaxError(column int, length int, code string, format string, args ...interface{}) *syntaxError {
+	return &syntaxError{column, length, code, fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,75 @@
+package asm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestDiagnostics(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Diagnostic formatting", func() {
+		d := Diagnostic{"Prog.asm", 3, 4, 1, SeverityError, CodeInvalidDest, "P is not a valid memory location", "   P=D+A", nil}
+		g.It("Should format a diagnostic in compiler style", func() {
+			g.Assert(d.Error()).Equal("Prog.asm:3:4: error: P is not a valid memory location [invalid-dest]")
+		})
+		g.It("Should underline the offending characters", func() {
+			g.Assert(d.Excerpt()).Equal("   P=D+A\n   ^")
+			d.Column, d.Length = 6, 3
+			g.Assert(d.Excerpt()).Equal("   P=D+A\n     ^~~")
+		})
+		g.It("Should keep tabs in the underline", func() {
+			d := Diagnostic{"Prog.asm", 1, 5, 3, SeverityError, CodeInvalidComp, "M+2 is not a valid comp value", "\tAM=M+2", nil}
+			g.Assert(d.Excerpt()).Equal("\tAM=M+2\n\t   ^~~")
+		})
+	})
+
+	g.Describe("Error collection", func() {
+		f, _ := os.Open("../test/Errors.asm")
+		defer f.Close()
+		var out bytes.Buffer
+		res, err := Assemble(f, &out, Options{Filename: "Errors.asm"})
+
+		g.It("Should return an AssemblyError", func() {
+			asmErr, ok := err.(*AssemblyError)
+			g.Assert(ok).IsTrue()
+			g.Assert(len(asmErr.Diagnostics)).Equal(5)
+			g.Assert(asmErr.Error()).Equal("5 error(s) found in Errors.asm")
+		})
+		g.It("Should print the errors with their excerpts", func() {
+			var printed, expected bytes.Buffer
+			err.(*AssemblyError).Print(&printed)
+			res.Diagnostics.Print(&expected)
+			g.Assert(printed.String()).Equal(expected.String())
+			g.Assert(bytes.Contains(printed.Bytes(), []byte("^"))).IsTrue()
+		})
+		g.It("Should report every error in the file", func() {
+
+			expected := []struct {
+				line   int
+				column int
+				code   string
+			}{
+				{3, 4, CodeInvalidDest},
+				{4, 1, CodeInvalidCommand},
+				{5, 6, CodeInvalidJump},
+				{6, 5, CodeInvalidComp},
+				{7, 2, CodeInvalidConstant},
+			}
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(len(expected))
+			for i, e := range expected {
+				g.Assert(items[i].File).Equal("Errors.asm")
+				g.Assert(items[i].Line).Equal(e.line)
+				g.Assert(items[i].Column).Equal(e.column)
+				g.Assert(items[i].Code).Equal(e.code)
+				g.Assert(items[i].Severity).Equal(SeverityError)
+			}
+		})
+		g.It("Should not write any output", func() {
+			g.Assert(out.Len()).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,217 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codes for problems found while disassembling
+const (
+	CodeInvalidWord        = "invalid-word"
+	CodeInvalidInstruction = "invalid-instruction"
+)
+
+// DisassembleOptions configures a call to Disassemble
+type DisassembleOptions struct {
+	// Filename labels diagnostics; it is not opened
+	Filename string
+	// Labels replaces the A-values that are followed by a jump with
+	// synthesized labels of the form L_0012
+	Labels bool
+	// Annotate adds a comment naming the predefined symbol (R0-R15,
+	// SCREEN, KBD...) that an A-value refers to
+	Annotate bool
+}
+
+// decoder inverts the Code translations, mapping each binary field back to its mnemonic
+type decoder struct {
+	comp map[uint16]CompMnemonic
+	dest map[uint16]MemoryLocation
+	jump map[uint16]JumpMnemonic
+}
+
+func newDecoder() decoder {
+	c := Code{}
+	d := decoder{map[uint16]CompMnemonic{}, map[uint16]MemoryLocation{}, map[uint16]JumpMnemonic{}}
+	for i := range CompStrings {
+		d.comp[c.Comp(CompMnemonic(i))] = CompMnemonic(i)
+	}
+	for i := range MemoryLocationStrings {
+		d.dest[c.Dest(MemoryLocation(i))] = MemoryLocation(i)
+	}
+	for i := range JumpStrings {
+		d.jump[c.Jump(JumpMnemonic(i))] = JumpMnemonic(i)
+	}
+	return d
+}
+
+// decode converts a 16-bit word back into a Command. The boolean result
+// is false if the word is a C instruction that does not encode a valid comp.
+func (d decoder) decode(word uint16) (Command, bool) {
+	if word&0x8000 == 0 {
+		return Command{A, Comp0, JmpNull, LocNull, strconv.Itoa(int(word))}, true
+	}
+	comp, ok := d.comp[(word>>6)&0x7f]
+	if !ok || word&0x6000 != 0x6000 {
+		return Command{}, false
+	}
+	return Command{C, comp, d.jump[word&0x7], d.dest[(word>>3)&0x7], ""}, true
+}
+
+// Disassemble reads binary .hack text from r and writes equivalent Hack
+// assembly to w, such that assembling the output reproduces the input.
+// Words that do not decode to a valid instruction are written as comments
+// and reported as warnings, so such programs do not round-trip. Lines that
+// are not 16 binary digits are reported as errors.
+func Disassemble(r io.Reader, w io.Writer, opts DisassembleOptions) (*Diagnostics, error) {
+	diags := &Diagnostics{}
+	words, lines, err := readWords(r, opts.Filename, diags)
+	if err != nil {
+		return diags, err
+	}
+	if diags.HasErrors() {
+		return diags, &AssemblyError{opts.Filename, diags.Items()}
+	}
+
+	dec := newDecoder()
+	cmds := make([]Command, len(words))
+	valid := make([]bool, len(words))
+	for i, word := range words {
+		cmds[i], valid[i] = dec.decode(word)
+		if !valid[i] {
+			diags.Add(Diagnostic{opts.Filename, i + 1, 1, 16, SeverityWarning, CodeInvalidInstruction,
+				fmt.Sprintf("%s does not decode to a valid instruction", lines[i]), lines[i], nil})
+		}
+	}
+
+	labels := map[int]string{}
+	if opts.Labels {
+		labels = synthesizeLabels(cmds, valid)
+	}
+	var predefined map[int]string
+	if opts.Annotate {
+		predefined = predefinedNames()
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, cmd := range cmds {
+		if label, ok := labels[i]; ok {
+			fmt.Fprintf(bw, "(%s)\n", label)
+		}
+		if !valid[i] {
+			fmt.Fprintf(bw, "// invalid instruction %s\n", lines[i])
+			continue
+		}
+		if cmd.ctype == C {
+			fmt.Fprintf(bw, "    %s\n", formatCInstruction(cmd))
+			continue
+		}
+		addr := int(words[i])
+		if label, ok := labels[addr]; ok && isJump(cmds, valid, i+1) {
+			fmt.Fprintf(bw, "    @%s\n", label)
+		} else if name, ok := predefined[addr]; ok {
+			fmt.Fprintf(bw, "    @%d // %s\n", addr, name)
+		} else {
+			fmt.Fprintf(bw, "    @%d\n", addr)
+		}
+	}
+	if label, ok := labels[len(cmds)]; ok {
+		fmt.Fprintf(bw, "(%s)\n", label)
+	}
+	return diags, bw.Flush()
+}
+
+// readWords reads .hack text, one 16-digit binary word per line, and
+// returns the words along with the lines they were read from. Lines that are
+// not a word are reported in diags and skipped.
+func readWords(r io.Reader, filename string, diags *Diagnostics) ([]uint16, []string, error) {
+	var words []uint16
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for l := 1; scanner.Scan(); l++ {
+		text := scanner.Text()
+		word, err := strconv.ParseUint(strings.TrimSpace(text), 2, 16)
+		if err != nil || len(strings.TrimSpace(text)) != 16 {
+			diags.Add(Diagnostic{filename, l, 1, len(text), SeverityError, CodeInvalidWord,
+				fmt.Sprintf("%s is not a 16-bit binary word", text), text, nil})
+			continue
+		}
+		words = append(words, uint16(word))
+		lines = append(lines, text)
+	}
+	return words, lines, scanner.Err()
+}
+
+// synthesizeLabels names every ROM address that is loaded into A
+// immediately before a jump
+func synthesizeLabels(cmds []Command, valid []bool) map[int]string {
+	labels := map[int]string{}
+	for i, cmd := range cmds {
+		if !valid[i] || cmd.ctype != A || !isJump(cmds, valid, i+1) {
+			continue
+		}
+		addr, _ := strconv.Atoi(cmd.symbol)
+		// A label may also mark the address just past the last instruction
+		if addr <= len(cmds) {
+			labels[addr] = fmt.Sprintf("L_%04d", addr)
+		}
+	}
+	return labels
+}
+
+func isJump(cmds []Command, valid []bool, i int) bool {
+	return i < len(cmds) && valid[i] && cmds[i].ctype == C && cmds[i].jump != JmpNull
+}
+
+// predefinedNames maps each address in the built-in symbol table to the
+// names that refer to it, e.g. "R0 (SP)"
+func predefinedNames() map[int]string {
+	st := InitializeSymbolTable()
+	byAddr := map[int][]string{}
+	for sym, addr := range st.table {
+		byAddr[addr] = append(byAddr[addr], sym)
+	}
+	names := map[int]string{}
+	for addr, syms := range byAddr {
+		// Put the register name first, then any aliases
+		sort.Slice(syms, func(i, j int) bool {
+			ri, rj := strings.HasPrefix(syms[i], "R"), strings.HasPrefix(syms[j], "R")
+			if ri != rj {
+				return ri
+			}
+			return syms[i] < syms[j]
+		})
+		names[addr] = syms[0]
+		if len(syms) > 1 {
+			names[addr] = fmt.Sprintf("%s (%s)", syms[0], strings.Join(syms[1:], ", "))
+		}
+	}
+	return names
+}
+
+// formatWord disassembles a single word without labels or annotations
+func (d decoder) formatWord(word uint16) string {
+	cmd, ok := d.decode(word)
+	switch {
+	case !ok:
+		return "invalid instruction"
+	case cmd.ctype == C:
+		return formatCInstruction(cmd)
+	}
+	return ACmdToken + cmd.symbol
+}
+
+func formatCInstruction(cmd Command) string {
+	out := CompStrings[cmd.comp]
+	if cmd.mloc != LocNull {
+		out = MemoryLocationStrings[cmd.mloc] + "=" + out
+	}
+	if cmd.jump != JmpNull {
+		out = out + ";" + JumpStrings[cmd.jump]
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package asm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestDisassembler(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Instruction decoding", func() {
+		dec := newDecoder()
+		g.It("Should decode an A instruction", func() {
+			cmd, ok := dec.decode(0x4000)
+			g.Assert(ok).IsTrue()
+			g.Assert(cmd.ctype).Equal(A)
+			g.Assert(cmd.symbol).Equal("16384")
+		})
+		g.It("Should decode a combined dest and jump C instruction", func() {
+			cmd, ok := dec.decode(0xfcad) // AM=M-1;JNE
+			g.Assert(ok).IsTrue()
+			g.Assert(formatCInstruction(cmd)).Equal("AM=M-1;JNE")
+		})
+		g.It("Should reject a word with no valid comp", func() {
+			_, ok := dec.decode(0xffc0)
+			g.Assert(ok).IsFalse()
+		})
+	})
+
+	g.Describe("Round trip", func() {
+		fixtures := []string{"Add", "Max", "MaxL", "Rect", "RectL", "Pong", "PongL"}
+		for _, name := range fixtures {
+			path := "../test/" + name + "Expected.hack"
+			for _, opts := range []DisassembleOptions{{Filename: path}, {Filename: path, Labels: true, Annotate: true}} {
+				opts := opts
+				g.It("Should reassemble "+name+" byte-for-byte", func() {
+					expected, _ := ioutil.ReadFile(path)
+					var src, out bytes.Buffer
+					_, err := Disassemble(bytes.NewReader(expected), &src, opts)
+					g.Assert(err == nil).IsTrue()
+					_, err = Assemble(&src, &out, Options{Filename: name + ".asm"})
+					g.Assert(err == nil).IsTrue()
+					g.Assert(out.String() == string(expected)).IsTrue()
+				})
+			}
+		}
+		g.It("Should define a label for a jump to the end of the program", func() {
+			expected := "0000000000000010\n1110001100000001\n"
+			var src, out bytes.Buffer
+			_, err := Disassemble(strings.NewReader(expected), &src, DisassembleOptions{Filename: "End.hack", Labels: true})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(src.String()).Equal("    @L_0002\n    D;JGT\n(L_0002)\n")
+			_, err = Assemble(&src, &out, Options{Filename: "End.asm"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out.String()).Equal(expected)
+		})
+	})
+
+	g.Describe("Invalid input", func() {
+		g.It("Should flag words that decode to no valid comp", func() {
+			var out bytes.Buffer
+			diags, err := Disassemble(strings.NewReader("0000000000000001\n1111111111000000\n"), &out, DisassembleOptions{Filename: "Bad.hack"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(diags.Items())).Equal(1)
+			g.Assert(diags.Items()[0].Line).Equal(2)
+			g.Assert(diags.Items()[0].Severity).Equal(SeverityWarning)
+			g.Assert(strings.Contains(out.String(), "// invalid instruction 1111111111000000")).IsTrue()
+		})
+		g.It("Should reject lines that are not 16-bit binary words", func() {
+			var out bytes.Buffer
+			diags, err := Disassemble(strings.NewReader("0000000000000001\n0102\n"), &out, DisassembleOptions{Filename: "Bad.hack"})
+			g.Assert(err != nil).IsTrue()
+			g.Assert(diags.Items()[0].Code).Equal(CodeInvalidWord)
+			g.Assert(out.Len()).Equal(0)
+		})
+	})
+}
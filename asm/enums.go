@@ -1,4 +1,4 @@
-package main
+package asm
 
 // CommandType is an integer enum type
 type CommandType int
@@ -8,16 +8,28 @@ type CommandType int
 // C is an operation
 // L is a symbol or variable assignment
 // Comment is a commented line that will be ignored
+// Global exports a label or variable from an object file
+// Extern declares a symbol that is defined by another object file
+// Equ defines a named constant with .equ or .define
+// Set defines a named constant that may be redefined with .set
+// Reserve declares an uninitialized RAM region with .data
+// Data declares an initialized RAM region with .word or .string
 const (
 	CmdNull CommandType = iota
 	A
 	C
 	L
 	Comment
+	Global
+	Extern
+	Equ
+	Set
+	Reserve
+	Data
 )
 
 // CommandTypeStrings enables converting a CommandType to and from its string representation
-var CommandTypeStrings = []string{"A", "C", "L", "Comment"}
+var CommandTypeStrings = []string{"A", "C", "L", "Comment", "Global", "Extern", "Equ", "Set", "Reserve", "Data"}
 
 // IsPrintable determines whether the command is a printable command (a or c type)
 // or a non-printable (comment or pseudo-command)
@@ -102,6 +114,27 @@ const (
 // CompStrings enables converting a Comp to and from its string representation
 var CompStrings = []string{"0", "1", "-1", "D", "A", "!D", "!A", "-D", "-A", "D+1", "A+1", "D-1", "A-1", "D+A", "D-A", "A-D", "D&A", "D|A", "M", "!M", "-M", "M+1", "M-1", "D+M", "D-M", "M-D", "D&M", "D|M"}
 
+// SymbolKind is an integer enum type
+type SymbolKind int
+
+// Enum for the possible kinds of symbol table entry:
+// SymPredefined is a built-in symbol such as SP, R0 or SCREEN
+// SymLabel is a ROM address declared with (LABEL)
+// SymVariable is a RAM address allocated for an undeclared symbol
+// SymExtern is a symbol declared with .extern, resolved by the linker
+// SymConstant is a value named by .equ or .set, which occupies no memory
+const (
+	SymNull SymbolKind = iota
+	SymPredefined
+	SymLabel
+	SymVariable
+	SymExtern
+	SymConstant
+)
+
+// SymbolKindStrings enables converting a SymbolKind to and from its string representation
+var SymbolKindStrings = []string{"null", "predefined", "label", "variable", "extern", "constant"}
+
 // EnumValFromString enables converting a string into an enum value
 func EnumValFromString(enumStrings []string, searchVal string) int {
 	for i, s := range enumStrings {
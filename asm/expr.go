@@ -0,0 +1,285 @@
+package asm
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CodeInvalidExpression identifies malformed or unevaluable A-instruction expressions
+const CodeInvalidExpression = "invalid-expression"
+
+// maxExprValue bounds the intermediate results of an expression, so that
+// overflow is reported rather than wrapping around
+const maxExprValue = 1 << 31
+
+// binaryPrecedence gives the binding strength of each binary operator, as in C
+var binaryPrecedence = map[string]int{
+	"|":  1,
+	"&":  2,
+	"<<": 3, ">>": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+// exprValue is the result of evaluating an expression. If the value is the
+// address of a relocatable symbol plus a constant, sym names the symbol; if
+// it depends on relocatable symbols in any other way, mixed is set.
+type exprValue struct {
+	n     int
+	sym   string
+	mixed bool
+}
+
+// exprToken is a single token of an expression. pos is its 0-based offset.
+type exprToken struct {
+	text  string
+	pos   int
+	ident bool
+	num   bool
+	value int
+}
+
+// exprParser evaluates an expression by precedence climbing. lookup returns
+// the value of an identifier, which starts at the given offset.
+type exprParser struct {
+	src    string
+	toks   []exprToken
+	i      int
+	lookup func(name string, pos int) (exprValue, error)
+}
+
+// evalExpr evaluates the operand of an A-instruction, such as SCREEN+32,
+// (ROWS*32), 0x4000, 0b1010 or 'A'
+func evalExpr(src string, lookup func(name string, pos int) (exprValue, error)) (exprValue, error) {
+	toks, err := tokenizeExpr(src)
+	if err != nil {
+		return exprValue{}, err
+	}
+	return evalTokens(src, toks, lookup)
+}
+
+// evalTokens evaluates an expression that has already been split into tokens
+func evalTokens(src string, toks []exprToken, lookup func(name string, pos int) (exprValue, error)) (exprValue, error) {
+	ep := &exprParser{src, toks, 0, lookup}
+	v, err := ep.parseBinary(1)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if tok := ep.peek(); tok != nil {
+		return exprValue{}, newSyntaxError(tok.pos+1, len(tok.text), CodeInvalidExpression, "unexpected %s in expression", tok.text)
+	}
+	return v, nil
+}
+
+func (ep *exprParser) peek() *exprToken {
+	if ep.i >= len(ep.toks) {
+		return nil
+	}
+	return &ep.toks[ep.i]
+}
+
+func (ep *exprParser) parseBinary(minPrec int) (exprValue, error) {
+	lhs, err := ep.parseUnary()
+	if err != nil {
+		return lhs, err
+	}
+	for {
+		tok := ep.peek()
+		if tok == nil || tok.ident || tok.num {
+			return lhs, nil
+		}
+		prec, ok := binaryPrecedence[tok.text]
+		if !ok || prec < minPrec {
+			return lhs, nil
+		}
+		ep.i++
+		rhs, err := ep.parseBinary(prec + 1)
+		if err != nil {
+			return rhs, err
+		}
+		if lhs, err = applyBinary(tok, lhs, rhs); err != nil {
+			return lhs, err
+		}
+	}
+}
+
+func (ep *exprParser) parseUnary() (exprValue, error) {
+	tok := ep.peek()
+	if tok != nil && !tok.num && !tok.ident && (tok.text == "-" || tok.text == "+") {
+		ep.i++
+		v, err := ep.parseUnary()
+		if err != nil || tok.text == "+" {
+			return v, err
+		}
+		return exprValue{-v.n, "", v.mixed || v.sym != ""}, nil
+	}
+	return ep.parsePrimary()
+}
+
+func (ep *exprParser) parsePrimary() (exprValue, error) {
+	tok := ep.peek()
+	if tok == nil {
+		return exprValue{}, newSyntaxError(len(ep.src)+1, 1, CodeInvalidExpression, "expression is incomplete")
+	}
+	ep.i++
+	switch {
+	case tok.num:
+		return exprValue{tok.value, "", false}, nil
+	case tok.ident:
+		return ep.lookup(tok.text, tok.pos)
+	case tok.text == "(":
+		v, err := ep.parseBinary(1)
+		if err != nil {
+			return v, err
+		}
+		if next := ep.peek(); next == nil || next.text != ")" || next.ident || next.num {
+			return v, newSyntaxError(tok.pos+1, 1, CodeInvalidExpression, "( is not closed")
+		}
+		ep.i++
+		return v, nil
+	}
+	return exprValue{}, newSyntaxError(tok.pos+1, len(tok.text), CodeInvalidExpression, "unexpected %s in expression", tok.text)
+}
+
+// applyBinary combines two values. Adding a constant to a relocatable
+// symbol, or subtracting one from it, keeps the result relocatable; any
+// other use of a relocatable symbol makes the result mixed.
+func applyBinary(op *exprToken, lhs exprValue, rhs exprValue) (exprValue, error) {
+	var n int
+	switch op.text {
+	case "+":
+		n = lhs.n + rhs.n
+	case "-":
+		n = lhs.n - rhs.n
+	case "*":
+		n = lhs.n * rhs.n
+	case "/", "%":
+		if rhs.n == 0 {
+			return lhs, newSyntaxError(op.pos+1, 1, CodeInvalidExpression, "division by zero")
+		}
+		n = lhs.n / rhs.n
+		if op.text == "%" {
+			n = lhs.n % rhs.n
+		}
+	case "&":
+		n = lhs.n & rhs.n
+	case "|":
+		n = lhs.n | rhs.n
+	case "<<", ">>":
+		if rhs.n < 0 || rhs.n > 16 {
+			return lhs, newSyntaxError(op.pos+1, 2, CodeInvalidExpression, "shift count %d is out of range", rhs.n)
+		}
+		n = lhs.n << uint(rhs.n)
+		if op.text == ">>" {
+			n = lhs.n >> uint(rhs.n)
+		}
+	}
+	if n >= maxExprValue || n <= -maxExprValue {
+		return lhs, newSyntaxError(op.pos+1, len(op.text), CodeInvalidConstant, "expression overflows at %s", op.text)
+	}
+
+	v := exprValue{n, "", lhs.mixed || rhs.mixed}
+	switch {
+	case lhs.sym == "" && rhs.sym == "":
+	case op.text == "+" && (lhs.sym == "" || rhs.sym == ""):
+		v.sym = lhs.sym + rhs.sym
+	case op.text == "-" && rhs.sym == "":
+		v.sym = lhs.sym
+	default:
+		v.mixed = true
+	}
+	return v, nil
+}
+
+// tokenizeExpr splits an expression into numbers, identifiers and operators
+func tokenizeExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end == -1 {
+				return nil, newSyntaxError(i+1, len(src)-i, CodeInvalidExpression, "character literal is not closed")
+			}
+			lit := src[i : i+end+2]
+			r, _, tail, err := strconv.UnquoteChar(lit[1:len(lit)-1], '\'')
+			if err != nil || tail != "" || r > 0x7fff {
+				return nil, newSyntaxError(i+1, len(lit), CodeInvalidExpression, "%s is not a valid character literal", lit)
+			}
+			toks = append(toks, exprToken{lit, i, false, true, int(r)})
+			i += len(lit)
+		case c >= '0' && c <= '9':
+			n := i
+			for n < len(src) && isSymbolChar(src[n]) {
+				n++
+			}
+			tok, err := numberToken(src[i:n], i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = n
+		case isSymbolChar(c):
+			n := i
+			for n < len(src) && isSymbolChar(src[n]) {
+				n++
+			}
+			toks = append(toks, exprToken{src[i:n], i, true, false, 0})
+			i = n
+		case strings.HasPrefix(src[i:], "<<") || strings.HasPrefix(src[i:], ">>"):
+			toks = append(toks, exprToken{src[i : i+2], i, false, false, 0})
+			i += 2
+		case strings.IndexByte("+-*/%&|()", c) != -1:
+			toks = append(toks, exprToken{src[i : i+1], i, false, false, 0})
+			i++
+		default:
+			return nil, newSyntaxError(i+1, 1, CodeInvalidExpression, "unexpected %c in expression", c)
+		}
+	}
+	return toks, nil
+}
+
+// numberToken converts a decimal, 0x hex or 0b binary literal. A numeric
+// label reference such as 1b or 2f is returned as an identifier.
+func numberToken(text string, pos int) (exprToken, error) {
+	if _, _, ok := numericReference(text); ok {
+		return exprToken{text, pos, true, false, 0}, nil
+	}
+	digits, base := text, 10
+	switch {
+	case strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X"):
+		digits, base = text[2:], 16
+	case strings.HasPrefix(text, "0b") || strings.HasPrefix(text, "0B"):
+		digits, base = text[2:], 2
+	}
+	n, err := strconv.ParseUint(digits, base, 16)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return exprToken{}, newSyntaxError(pos+1, len(text), CodeInvalidConstant, "%s does not fit in 16 bits", text)
+		}
+		return exprToken{}, newSyntaxError(pos+1, len(text), CodeInvalidExpression, "%s is not a valid number", text)
+	}
+	return exprToken{text, pos, false, true, int(n)}, nil
+}
+
+// isSymbol returns whether a name consists only of the characters allowed in
+// Hack symbols, and does not start with a digit
+func isSymbol(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isSymbolChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSymbolChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == ':' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
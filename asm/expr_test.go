@@ -0,0 +1,102 @@
+package asm
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestExpressions(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Expression evaluation", func() {
+		cases := []struct {
+			expr     string
+			expected int
+		}{
+			{"SCREEN+32", 16416},
+			{"KBD-1", 24575},
+			{"(3+4)*2", 14},
+			{"3+4*2", 11},
+			{"0x4000", 16384},
+			{"0b1010", 10},
+			{"'A'", 65},
+			{"'\\n'", 10},
+			{"17/5", 3},
+			{"17 % 5", 2},
+			{"0xff & 0x0f | 0x30", 0x3f},
+			{"1<<14", 16384},
+			{"SCREEN>>8", 64},
+			{"-2+5", 3},
+			{"R15-R1", 14},
+			{"LOOP+1", 2},
+			{"(LOOP)", 1},
+		}
+		for _, c := range cases {
+			c := c
+			g.It("Should evaluate "+c.expr, func() {
+				out, _, err := assembleSource("    @0", "(LOOP)", "    @"+c.expr)
+				expected, _, _ := assembleSource("@0", "@"+strconv.Itoa(c.expected))
+				g.Assert(err == nil).IsTrue()
+				g.Assert(out).Equal(expected)
+			})
+		}
+	})
+
+	g.Describe("Expression errors", func() {
+		cases := []struct {
+			expr    string
+			column  int
+			code    string
+			message string
+		}{
+			{"SCREEN*2", 6, CodeInvalidConstant, "SCREEN*2 evaluates to 32768, which does not fit in 15 bits"},
+			{"1-2", 6, CodeInvalidConstant, "1-2 evaluates to -1, which does not fit in 15 bits"},
+			{"0x10000", 6, CodeInvalidConstant, "0x10000 does not fit in 16 bits"},
+			{"32767*32767*32767", 17, CodeInvalidConstant, "expression overflows at *"},
+			{"WIDTH*2", 6, CodeUndefinedSymbol, "WIDTH is not defined"},
+			{"10/(5-5)", 8, CodeInvalidExpression, "division by zero"},
+			{"(1+2", 6, CodeInvalidExpression, "( is not closed"},
+			{"1+", 8, CodeInvalidExpression, "expression is incomplete"},
+			{"2 3", 8, CodeInvalidExpression, "unexpected 3 in expression"},
+			{"0x1g", 6, CodeInvalidExpression, "0x1g is not a valid number"},
+			{"1#2", 7, CodeInvalidExpression, "unexpected # in expression"},
+		}
+		for _, c := range cases {
+			c := c
+			g.It("Should report "+c.message, func() {
+				_, res, err := assembleSource("    @" + c.expr)
+				g.Assert(err == nil).IsFalse()
+				items := res.Diagnostics.Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Column).Equal(c.column)
+				g.Assert(items[0].Code).Equal(c.code)
+				g.Assert(items[0].Message).Equal(c.message)
+			})
+		}
+	})
+
+	g.Describe("Relocatable expressions", func() {
+		g.It("Should record an addend for a label plus a constant", func() {
+			var out bytes.Buffer
+			src := "(START)\n    @START+2\n    @count\n    @count-1\n    @SCREEN+1"
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", Object: true})
+			g.Assert(err == nil).IsTrue()
+			obj, _ := LoadObject(&out)
+			g.Assert(len(obj.Fixups)).Equal(3)
+			g.Assert(obj.Fixups[0].Addend).Equal(2)
+			g.Assert(obj.Fixups[2].Symbol).Equal("count")
+			g.Assert(obj.Fixups[2].Addend).Equal(-1)
+		})
+		g.It("Should reject expressions that cannot be relocated", func() {
+			var out bytes.Buffer
+			src := "(START)\n    @START*2"
+			res, _ := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", Object: true})
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Code).Equal(CodeInvalidExpression)
+		})
+	})
+}
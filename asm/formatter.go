@@ -0,0 +1,79 @@
+package asm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// indent is the indentation of instructions in formatted source
+const indent = "    "
+
+// FormatSource rewrites Hack assembly in a canonical layout:
+//
+//   - labels and directives start in the first column, and instructions
+//     and macro invocations are indented by four spaces;
+//   - blanks are removed from C-instructions, after the @ of A-instructions
+//     and inside the parentheses of labels, so D = M + 1 becomes D=M+1;
+//   - inline comments are separated from the code by a single space, and
+//     comment lines are indented like instructions if they were indented;
+//   - trailing blanks, runs of blank lines and blank lines at the start and
+//     end of the file are removed, and lines end in LF.
+//
+// The source is not assembled, so lines that are not valid are only
+// re-indented, and formatting formatted source leaves it unchanged.
+func FormatSource(r io.Reader, w io.Writer) error {
+	lines, err := readLines(r, "")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	started, blank := false, false
+	for _, l := range lines {
+		text := formatLine(l.Text)
+		if text == "" {
+			blank = started
+			continue
+		}
+		if blank {
+			bw.WriteString("\n")
+		}
+		bw.WriteString(text + "\n")
+		started, blank = true, false
+	}
+	return bw.Flush()
+}
+
+// formatLine returns a single line in the canonical layout
+func formatLine(line string) string {
+	code, comment := splitComment(line)
+	comment = strings.TrimRight(comment, " \t")
+	code = strings.Trim(code, " \t")
+	switch {
+	case code == "" && comment == "":
+		return ""
+	case code == "":
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			return indent + comment
+		}
+		return comment
+	case strings.HasPrefix(code, "(") && strings.HasSuffix(code, ")"):
+		code = "(" + strings.Trim(code[1:len(code)-1], " \t") + ")"
+	case strings.HasPrefix(code, "(") || strings.HasPrefix(code, "."):
+	case strings.HasSuffix(code, ":"):
+		if _, ok := numericLabel(code[:len(code)-1]); !ok {
+			code = indent + code
+		}
+	case strings.HasPrefix(code, ACmdToken):
+		code = indent + ACmdToken + strings.TrimLeft(code[1:], " \t")
+	default:
+		if compacted, _, err := compact(code); err == nil {
+			code = compacted
+		}
+		code = indent + code
+	}
+	if comment != "" {
+		code += " " + comment
+	}
+	return code
+}
@@ -0,0 +1,58 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestFormatter(t *testing.T) {
+	g := Goblin(t)
+	format := func(src string) string {
+		var out bytes.Buffer
+		if err := FormatSource(strings.NewReader(src), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	g.Describe("Source formatting", func() {
+		g.It("Should lay out labels, directives, instructions and comments", func() {
+			src := "\r\n\n// Adds one\n.equ ONE 1\n( LOOP )  \n  @ i   // counter\n D = M + 1\n\n\n\t0 ; JMP\n  // done\n\n"
+			expected := "// Adds one\n.equ ONE 1\n(LOOP)\n    @i // counter\n    D=M+1\n\n    0;JMP\n    // done\n"
+			g.Assert(format(src)).Equal(expected)
+		})
+		g.It("Should only re-indent lines that are not valid", func() {
+			g.Assert(format("D M = 1\n")).Equal("    D M = 1\n")
+		})
+		g.It("Should leave formatted source unchanged", func() {
+			src := "(LOOP)\n    @i // counter\n    D=M+1\n\n    0;JMP\n"
+			g.Assert(format(src)).Equal(src)
+			g.Assert(format(format("  @ x\n(A)\n  M = D\n"))).Equal(format("  @ x\n(A)\n  M = D\n"))
+		})
+	})
+	g.Describe("Formatted programs", func() {
+		g.It("Should assemble to the same output as before formatting", func() {
+			src := ".string url \"http://x\"  // link\n.word w '/',1\n  @ url\n D = M+1 // next\n"
+			before, _, err := assembleSource(src)
+			g.Assert(err == nil).IsTrue()
+			formatted := format(src)
+			g.Assert(formatted).Equal(".string url \"http://x\" // link\n.word w '/',1\n    @url\n    D=M+1 // next\n")
+			after, _, err := assembleSource(formatted)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(after).Equal(before)
+		})
+	})
+	g.Describe("Symbol file", func() {
+		g.It("Should list labels, variables and constants", func() {
+			_, res, err := assembleSource(".equ N 5", "(MAIN)", "    @x", "    @MAIN")
+			g.Assert(err == nil).IsTrue()
+			var out bytes.Buffer
+			g.Assert(res.Symbols.WriteSymbols(&out) == nil).IsTrue()
+			g.Assert(strings.Contains(out.String(), "label MAIN 0\n")).IsTrue()
+			g.Assert(strings.Contains(out.String(), "variable x 16\n")).IsTrue()
+			g.Assert(strings.Contains(out.String(), "constant N 5\n")).IsTrue()
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package asm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Codes for problems found while including files
+const (
+	CodeInvalidInclude = "invalid-include"
+	CodeIncludeMissing = "include-not-found"
+	CodeIncludeCycle   = "include-cycle"
+)
+
+// includer replaces .include directives with the lines of the named file
+type includer struct {
+	paths  []string
+	diags  *Diagnostics
+	active []string
+	once   map[string]bool
+}
+
+func newIncluder(paths []string, diags *Diagnostics) *includer {
+	return &includer{paths, diags, nil, map[string]bool{}}
+}
+
+// expand returns the lines of a file with every .include directive replaced
+// by the lines of the included file, recursively. A file containing .once is
+// only included the first time it is named. file is the path the lines were
+// read from, and is used to detect cycles.
+func (inc *includer) expand(lines []SourceLine, file string) []SourceLine {
+	key := includeKey(file)
+	inc.active = append(inc.active, key)
+	defer func() { inc.active = inc.active[:len(inc.active)-1] }()
+
+	out := make([]SourceLine, 0, len(lines))
+	for _, l := range lines {
+		fields := strings.Fields(stripInlineComments(l.Text))
+		switch {
+		case len(fields) > 0 && fields[0] == OnceToken:
+			inc.once[key] = true
+		case len(fields) > 0 && fields[0] == IncludeToken:
+			out = append(out, inc.include(l)...)
+		default:
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// include reads the file named by an .include directive
+func (inc *includer) include(l SourceLine) []SourceLine {
+	text := stripInlineComments(l.Text)
+	col := strings.Index(l.Text, IncludeToken) + 1
+	arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), IncludeToken))
+	name, err := strconv.Unquote(arg)
+	if err != nil || name == "" || !strings.HasPrefix(arg, `"`) {
+		inc.report(l, col, len(IncludeToken), CodeInvalidInclude, "%s requires a quoted file name", IncludeToken)
+		return nil
+	}
+	argCol := strings.Index(l.Text, arg) + 1
+
+	path, ok := inc.resolve(name, l.File)
+	if !ok {
+		inc.report(l, argCol, len(arg), CodeIncludeMissing, "%s was not found", name)
+		return nil
+	}
+	key := includeKey(path)
+	for i, active := range inc.active {
+		if active == key {
+			chain := append(append([]string{}, inc.active[i:]...), key)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			inc.report(l, argCol, len(arg), CodeIncludeCycle, "%s includes itself (%s)", name, strings.Join(chain, " -> "))
+			return nil
+		}
+	}
+	if inc.once[key] {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		inc.report(l, argCol, len(arg), CodeIncludeMissing, "Unable to open %s: %s", name, err)
+		return nil
+	}
+	defer f.Close()
+	lines, err := readLines(f, path)
+	if err != nil {
+		inc.report(l, argCol, len(arg), CodeIncludeMissing, "Unable to read %s: %s", name, err)
+		return nil
+	}
+	for i := range lines {
+		lines[i].Module = l.Module
+	}
+	return inc.expand(lines, path)
+}
+
+// resolve looks for an included file relative to the directory of the file
+// that includes it, then in each of the include paths in order
+func (inc *includer) resolve(name string, from string) (string, bool) {
+	if filepath.IsAbs(name) {
+		_, err := os.Stat(name)
+		return name, err == nil
+	}
+	dirs := append([]string{filepath.Dir(from)}, inc.paths...)
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (inc *includer) report(l SourceLine, column int, length int, code string, format string, args ...interface{}) {
+	inc.diags.Add(newLineDiagnostic(l, SeverityError, column, length, code, fmt.Sprintf(format, args...)))
+}
+
+// includeKey identifies a file regardless of the relative path used to name it
+func includeKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
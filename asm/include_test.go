@@ -0,0 +1,71 @@
+package asm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestInclude(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Include directive", func() {
+		g.It("Should search the include paths and honour .once", func() {
+			f, _ := os.Open("../test/include/Main.asm")
+			defer f.Close()
+			var out bytes.Buffer
+			opts := Options{Filename: "../test/include/Main.asm", IncludePaths: []string{"../test/include/lib"}}
+			_, err := Assemble(f, &out, opts)
+			g.Assert(err == nil).IsTrue()
+			expected, _, _ := assembleSource("@7", "D=A", "@16", "M=D", "@4", "0;JMP")
+			g.Assert(out.String()).Equal(expected)
+		})
+		g.It("Should report missing files at the directive", func() {
+			f, _ := os.Open("../test/include/Main.asm")
+			defer f.Close()
+			var out bytes.Buffer
+			res, _ := Assemble(f, &out, Options{Filename: "../test/include/Main.asm"})
+			items := res.Diagnostics.Items()
+			g.Assert(len(items) > 0).IsTrue()
+			g.Assert(items[0].Line).Equal(2)
+			g.Assert(items[0].Column).Equal(10)
+			g.Assert(items[0].Code).Equal(CodeIncludeMissing)
+		})
+		g.It("Should detect include cycles", func() {
+			var out bytes.Buffer
+			res, err := AssembleFiles([]string{"../test/include/CycleA.asm"}, &out, Options{})
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].File).Equal("../test/include/CycleB.asm")
+			g.Assert(items[0].Line).Equal(2)
+			g.Assert(items[0].Code).Equal(CodeIncludeCycle)
+			g.Assert(items[0].Message).Equal("CycleA.asm includes itself (CycleA.asm -> CycleB.asm -> CycleA.asm)")
+		})
+	})
+
+	g.Describe("Multi-file assembly", func() {
+		paths := []string{"../test/include/Main.asm", "../test/include/Second.asm"}
+		opts := Options{IncludePaths: []string{"../test/include/lib"}}
+
+		g.It("Should share the symbol table between files", func() {
+			var out bytes.Buffer
+			res, _ := AssembleFiles(paths[:1], &out, opts)
+			g.Assert(res.Symbols.GetAddress("result")).Equal(16)
+			res, _ = AssembleFiles(paths, &out, opts)
+			g.Assert(res.Symbols.GetAddress("result")).Equal(16)
+			g.Assert(res.Symbols.GetAddress("value")).Equal(17)
+		})
+		g.It("Should report the original file and line of each problem", func() {
+			var out bytes.Buffer
+			res, err := AssembleFiles(paths, &out, opts)
+			g.Assert(err.Error()).Equal("1 error(s) found in " + paths[0] + ", " + paths[1])
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].File).Equal(paths[1])
+			g.Assert(items[0].Line).Equal(6)
+			g.Assert(out.Len()).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,158 @@
+package asm
+
+import "strconv"
+
+// instruction is a line of the program as parsed by the first pass. The
+// second pass only resolves the operand of an A-instruction and encodes it,
+// so the source is parsed once and never read again. index is the position
+// of the line in the program. undefined is a name that a directive on the
+// line used before it was defined, if any. generated is set on instructions
+// that the assembler added to the line, such as the A=!A that follows a
+// negative literal.
+type instruction struct {
+	src       SourceLine
+	index     int
+	cmd       Command
+	diag      *Diagnostic
+	operand   *operand
+	undefined *symbolRef
+	value     int
+	words     []uint16
+	res       resolution
+	generated bool
+}
+
+// newDiagnostic creates a diagnostic pointing at the given column of the line
+func (ins *instruction) newDiagnostic(sev Severity, column int, length int, code string, msg string) Diagnostic {
+	return newLineDiagnostic(ins.src, sev, column, length, code, msg)
+}
+
+// operand is the symbolic operand of an A-instruction, such as LOOP or
+// SCREEN+32, whose value is only known once every label is defined. column
+// is the 1-based position of text in the line. An expression is kept as
+// tokens, and refs holds the qualified name of each of its identifiers in
+// order; a plain symbol has no tokens and one ref.
+type operand struct {
+	text   string
+	column int
+	toks   []exprToken
+	refs   []symbolRef
+}
+
+// resolution is the value an operand resolves to. sym is the symbol that
+// the operand loads, or that is added to addend to give the value; mixed is
+// set if the value depends on relocatable symbols in any other way.
+type resolution struct {
+	value  int
+	sym    string
+	addend int
+	mixed  bool
+}
+
+// newOperand qualifies the names in an A-instruction operand by the scope
+// of the line it is on. Expressions are split into tokens, but evaluated
+// only when the operand is resolved.
+func (s *scope) newOperand(text string, column int, l SourceLine) (*operand, error) {
+	op := &operand{text, column, nil, nil}
+	if isSymbol(text) {
+		op.refs = append(op.refs, s.reference(text, l))
+		return op, nil
+	}
+	toks, err := tokenizeExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	op.toks = toks
+	for _, tok := range toks {
+		if tok.ident {
+			op.refs = append(op.refs, s.reference(tok.text, l))
+		}
+	}
+	return op, nil
+}
+
+// resolveOperand returns the value of an operand on the given line. If
+// check is set, every label must already be known: references that cannot
+// be resolved are reported, and plain symbols that are not defined are
+// allocated as variables. Otherwise only symbols that are already known are
+// looked up, and expressions are not evaluated.
+func (s *scope) resolveOperand(op *operand, l SourceLine, st *SymbolTable, check bool) (resolution, error) {
+	if !isSymbol(op.text) {
+		if !check {
+			return resolution{}, nil
+		}
+		return s.resolveExpression(op, l, st)
+	}
+	ref := op.refs[0]
+	if check {
+		if err := s.check(ref, l, st); err != nil {
+			return resolution{}, err
+		}
+		if !st.Contains(ref.qualified) {
+			st.AddElement(ref.qualified, -1)
+		}
+	}
+	addr := st.GetAddress(ref.qualified)
+	if st.Contains(ref.qualified) && (addr < 0 || addr > 0x7fff) {
+		verb := "resolves to"
+		if st.Kind(ref.qualified) == SymConstant {
+			verb = "="
+		}
+		return resolution{}, newSyntaxError(1, len(op.text), CodeInvalidConstant, "%s %s %d, which does not fit in 15 bits", op.text, verb, addr)
+	}
+	return resolution{addr, ref.qualified, 0, false}, nil
+}
+
+// resolveExpression evaluates an operand that is not a plain symbol.
+// Identifiers in an expression must already be defined, as they are never
+// allocated as variables.
+func (s *scope) resolveExpression(op *operand, l SourceLine, st *SymbolTable) (resolution, error) {
+	refs := op.refs
+	lookup := func(name string, pos int) (exprValue, error) {
+		// Identifiers are looked up in the order they appear
+		ref := refs[0]
+		refs = refs[1:]
+		if err := s.check(ref, l, st); err != nil {
+			if se, ok := err.(*syntaxError); ok {
+				se.column += pos
+			}
+			return exprValue{}, err
+		}
+		v := exprValue{st.GetAddress(ref.qualified), "", false}
+		switch st.Kind(ref.qualified) {
+		case SymNull:
+			return v, newSyntaxError(pos+1, len(name), CodeUndefinedSymbol, "%s is not defined", name)
+		case SymLabel, SymVariable, SymExtern:
+			v.sym = ref.qualified
+		}
+		return v, nil
+	}
+	v, err := evalTokens(op.text, op.toks, lookup)
+	if err != nil {
+		return resolution{}, err
+	}
+	if v.n < 0 || v.n > 0x7fff {
+		return resolution{}, newSyntaxError(1, len(op.text), CodeInvalidConstant, "%s evaluates to %d, which does not fit in 15 bits", op.text, v.n)
+	}
+	res := resolution{v.n, "", 0, v.mixed}
+	if v.sym != "" {
+		res.sym, res.addend = v.sym, v.n-st.GetAddress(v.sym)
+	}
+	return res, nil
+}
+
+// resolve resolves the operand of an A-instruction in the second pass. If it
+// cannot be resolved, the line is reported and no longer counts as an instruction.
+func (asm *assembly) resolve(ins *instruction, s *scope) {
+	res, err := s.resolveOperand(ins.operand, ins.src, &asm.st, true)
+	if err != nil {
+		if se, ok := err.(*syntaxError); ok {
+			se.column += ins.operand.column - 1
+		}
+		d := lineError(ins.src, err)
+		ins.diag, ins.cmd = &d, Command{CmdNull, Comp0, JmpNull, LocNull, ""}
+		return
+	}
+	ins.res = res
+	ins.cmd.symbol = strconv.Itoa(res.value)
+}
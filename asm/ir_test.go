@@ -0,0 +1,64 @@
+package asm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestIR(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Two-pass assembly", func() {
+		g.It("Should evaluate expressions only once every label is known", func() {
+			out, _, err := assembleSource(
+				"    @END/END",
+				"    D=A",
+				"(END)",
+				"    @END",
+			)
+			expected, _, _ := assembleSource("@1", "D=A", "@2")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+		g.It("Should point errors found in the second pass at the operand", func() {
+			_, res, err := assembleSource(
+				"(MAIN)",
+				"    @ .loop // not defined",
+				"    @MAIN+(1",
+			)
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Code).Equal(CodeLocalLabel)
+			g.Assert(items[0].Column).Equal(7)
+			g.Assert(items[0].Length).Equal(5)
+			g.Assert(items[1].Message).Equal("( is not closed")
+			g.Assert(items[1].Column).Equal(11)
+		})
+		g.It("Should allocate variables in the order they are first used", func() {
+			_, res, err := assembleSource("@b", "(L)", "@a", "@b", "@L", "@c")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(res.Symbols.GetAddress("b")).Equal(16)
+			g.Assert(res.Symbols.GetAddress("a")).Equal(17)
+			g.Assert(res.Symbols.GetAddress("c")).Equal(18)
+		})
+		g.It("Should read the source from standard input", func() {
+			r, w, _ := os.Pipe()
+			stdin := os.Stdin
+			os.Stdin = r
+			defer func() { os.Stdin = stdin }()
+			go func() {
+				w.WriteString("@x\nD=Q\n")
+				w.Close()
+			}()
+			var out bytes.Buffer
+			res, err := AssembleFiles([]string{StdinPath}, &out, Options{})
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Error()).Equal("<stdin>:2:3: error: Q is not a valid comp value [invalid-comp]")
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package asm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which some editors write at
+// the start of a file
+const byteOrderMark = "\ufeff"
+
+// cOperators are the characters other than those of symbols that can appear
+// in a C instruction
+const cOperators = "=;+-!&|"
+
+// commentIndex returns the position of the // that starts the comment on a
+// line, or -1 if there is none. A // inside a string or character literal,
+// as in .string "http://x", does not start a comment.
+func commentIndex(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(line[i:], CommentToken):
+			return i
+		}
+	}
+	return -1
+}
+
+// splitComment splits a line into its code and its comment, which starts
+// with // or is empty
+func splitComment(line string) (string, string) {
+	if i := commentIndex(line); i != -1 {
+		return line[:i], line[i:]
+	}
+	return line, ""
+}
+
+// compact removes the blanks from a C instruction, and returns the column in
+// line of each character that remains. Blanks may surround = and ; and the
+// operators of the comp, so D = M and 0 ; JMP are accepted, but they may not
+// split a mnemonic such as AM or JMP. Characters that cannot appear in a C
+// instruction are rejected.
+func compact(line string) (string, []int, error) {
+	var b strings.Builder
+	var cols []int
+	var prev byte
+	gap := -1
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			if gap == -1 {
+				gap = i
+			}
+			continue
+		case c >= utf8.RuneSelf || (!isSymbolChar(c) && strings.IndexByte(cOperators, c) == -1):
+			r, size := utf8.DecodeRuneInString(line[i:])
+			return "", nil, newSyntaxError(i+1, size, CodeInvalidCommand, "unexpected %c in instruction", r)
+		case gap != -1 && isSymbolChar(c) && isSymbolChar(prev):
+			return "", nil, newSyntaxError(gap+1, i-gap, CodeInvalidCommand, "unexpected space between %s and %s",
+				lastWord(line[:gap]), leadingWord(line[i:]))
+		}
+		gap, prev = -1, c
+		b.WriteByte(c)
+		cols = append(cols, i+1)
+	}
+	return b.String(), cols, nil
+}
+
+// expandColumns converts the position of a syntax error in compacted text
+// into the position of the same characters in the original line
+func expandColumns(err error, cols []int) error {
+	se, ok := err.(*syntaxError)
+	if !ok || len(cols) == 0 {
+		return err
+	}
+	first := clamp(se.column-1, 0, len(cols)-1)
+	last := clamp(se.column+se.length-2, first, len(cols)-1)
+	se.column, se.length = cols[first], cols[last]-cols[first]+1
+	return se
+}
+
+func clamp(n int, lo int, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// lastWord returns the symbol characters at the end of s
+func lastWord(s string) string {
+	i := len(s)
+	for i > 0 && isSymbolChar(s[i-1]) {
+		i--
+	}
+	return s[i:]
+}
+
+// leadingWord returns the symbol characters at the start of s
+func leadingWord(s string) string {
+	i := 0
+	for i < len(s) && isSymbolChar(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+// checkSymbol reports a name that is not a valid Hack symbol, which starts at
+// the given column
+func checkSymbol(name string, column int) error {
+	if isSymbol(name) {
+		return nil
+	}
+	return newSyntaxError(column, len(name), CodeInvalidSymbol,
+		"%s is not a valid symbol; symbols consist of letters, digits, _ . $ and :, and do not start with a digit", name)
+}
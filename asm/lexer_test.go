@@ -0,0 +1,82 @@
+package asm
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestLexer(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Whitespace", func() {
+		g.It("Should accept blanks around operators", func() {
+			out, _, err := assembleSource("  D = M", "\t0 ; JMP", "  AM = M + 1 // inc", "@ \tR1", "( LOOP )", "@LOOP")
+			expected, _, _ := assembleSource("D=M", "0;JMP", "AM=M+1", "@R1", "(LOOP)", "@LOOP")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+		g.It("Should accept CRLF line endings and a byte order mark", func() {
+			out, _, err := assembleSource(byteOrderMark+"@2\r", "D=A\r", "(END)\r", "@END\r", "0;JMP\r")
+			expected, _, _ := assembleSource("@2", "D=A", "(END)", "@END", "0;JMP")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+	})
+
+	g.Describe("Comments", func() {
+		g.It("Should find comments outside literals", func() {
+			cases := map[string]int{
+				"D=M // x":                4,
+				"//":                      0,
+				".string s \"a//b\"":      -1,
+				".string s \"\\\"//\" //": 17,
+				"@'/' // '/'":             5,
+				"@'\\'' // x":             6,
+			}
+			for line, i := range cases {
+				g.Assert(commentIndex(line)).Equal(i)
+			}
+		})
+	})
+
+	g.Describe("Invalid lines", func() {
+		cases := []struct {
+			line    string
+			column  int
+			length  int
+			code    string
+			message string
+		}{
+			{"(LOOP", 1, 5, CodeInvalidCommand, "label (LOOP is missing a closing )"},
+			{"()", 1, 2, CodeInvalidCommand, "label has no name"},
+			{"(LOOP) D=M", 8, 3, CodeInvalidCommand, "unexpected D=M after label"},
+			{"(2nd)", 2, 3, CodeInvalidSymbol,
+				"2nd is not a valid symbol; symbols consist of letters, digits, _ . $ and :, and do not start with a digit"},
+			{"(a-b)", 2, 3, CodeInvalidSymbol,
+				"a-b is not a valid symbol; symbols consist of letters, digits, _ . $ and :, and do not start with a digit"},
+			{"  A M=D", 4, 1, CodeInvalidCommand, "unexpected space between A and M"},
+			{"  0;J MP", 6, 1, CodeInvalidCommand, "unexpected space between J and MP"},
+			{"  D=M#", 6, 1, CodeInvalidCommand, "unexpected # in instruction"},
+			{"  D = Q + 1", 7, 5, CodeInvalidComp, "Q+1 is not a valid comp value"},
+			{"  0 ; JXX", 7, 3, CodeInvalidJump, "JXX is not a valid jump expression"},
+			{"hello", 1, 5, CodeInvalidCommand, "hello is not a valid instruction"},
+			{".glob x", 1, 5, CodeInvalidCommand, ".glob is not a known directive"},
+			{".extern 9z", 9, 2, CodeInvalidSymbol,
+				"9z is not a valid symbol; symbols consist of letters, digits, _ . $ and :, and do not start with a digit"},
+			{".equ .x 1", 6, 2, CodeInvalidSymbol, ".x cannot be local"},
+		}
+		for _, c := range cases {
+			c := c
+			g.It("Should report "+c.message, func() {
+				_, res, err := assembleSource(c.line)
+				g.Assert(err == nil).IsFalse()
+				items := res.Diagnostics.Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Column).Equal(c.column)
+				g.Assert(items[0].Length).Equal(c.length)
+				g.Assert(items[0].Code).Equal(c.code)
+				g.Assert(items[0].Message).Equal(c.message)
+			})
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Codes for problems found while linking
+const (
+	CodeDuplicateSymbol = "duplicate-symbol"
+	CodeUndefinedSymbol = "undefined-symbol"
+)
+
+// LinkOptions configures a call to Link
+type LinkOptions struct {
+	// Filename labels the returned error; it is not opened
+	Filename string
+	// Format selects the file format of the output; the default is .hack text
+	Format Format
+}
+
+// Link combines object files into a single ROM image written to w. The
+// objects are placed in ROM in the order given, the variables of every
+// object are allocated in turn from RAM address 16, and each fixup is
+// resolved against the labels and variables of its own object and then
+// against the symbols exported by all of them. Symbols exported by more
+// than one object and references that cannot be resolved are reported,
+// in which case nothing is written and an *AssemblyError is returned.
+func Link(objs []*Object, w io.Writer, opts LinkOptions) (*Diagnostics, error) {
+	diags := &Diagnostics{}
+	bases := make([]int, len(objs))
+	vars := make([]map[string]int, len(objs))
+	rom, ram := 0, 16
+	for i, obj := range objs {
+		bases[i] = rom
+		rom += len(obj.Code)
+		vars[i] = map[string]int{}
+		for _, v := range obj.Variables {
+			vars[i][v] = ram
+			ram++
+		}
+	}
+	if rom > ROMSize {
+		diags.Add(Diagnostic{opts.Filename, 0, 0, 0, SeverityError, CodeROMOverflow,
+			fmt.Sprintf("the objects take %d words, which do not fit in the %d-word ROM", rom, ROMSize), "", nil})
+	}
+
+	globals := map[string]int{}
+	owners := map[string]*Object{}
+	for i, obj := range objs {
+		for _, e := range obj.Exports {
+			addr, ok := resolveLocal(obj, e.Name, bases[i], vars[i])
+			if !ok {
+				diags.Add(Diagnostic{e.File, e.Line, e.Column, len(e.Name), SeverityError, CodeUndefinedSymbol,
+					fmt.Sprintf("%s is exported but not defined", e.Name), "", nil})
+				continue
+			}
+			if owner, dup := owners[e.Name]; dup {
+				diags.Add(Diagnostic{e.File, e.Line, e.Column, len(e.Name), SeverityError, CodeDuplicateSymbol,
+					fmt.Sprintf("%s is also exported by %s", e.Name, owner.Source), "", nil})
+				continue
+			}
+			globals[e.Name], owners[e.Name] = addr, obj
+		}
+	}
+
+	var out bytes.Buffer
+	ow := NewOutputWriter(opts.Format, &out)
+	for i, obj := range objs {
+		code := append([]uint16{}, obj.Code...)
+		for _, f := range obj.Fixups {
+			addr, ok := resolveLocal(obj, f.Symbol, bases[i], vars[i])
+			if !ok {
+				addr, ok = globals[f.Symbol]
+			}
+			if !ok {
+				diags.Add(Diagnostic{f.File, f.Line, f.Column, len(f.Symbol), SeverityError, CodeUndefinedSymbol,
+					fmt.Sprintf("%s is not exported by any object", f.Symbol), "", nil})
+				continue
+			}
+			if addr += f.Addend; addr < 0 || addr > 0x7fff {
+				diags.Add(Diagnostic{f.File, f.Line, f.Column, len(f.Symbol), SeverityError, CodeInvalidConstant,
+					fmt.Sprintf("%s%+d resolves to %d, which does not fit in 15 bits", f.Symbol, f.Addend, addr), "", nil})
+				continue
+			}
+			code[f.Address] = uint16(addr)
+		}
+		for _, word := range code {
+			if err := ow.WriteWord(word); err != nil {
+				return diags, err
+			}
+		}
+	}
+	if err := ow.Close(); err != nil {
+		return diags, err
+	}
+	if diags.HasErrors() {
+		return diags, &AssemblyError{opts.Filename, diags.Items()}
+	}
+	_, err := out.WriteTo(w)
+	return diags, err
+}
+
+// resolveLocal returns the final address of a label or variable defined by an object
+func resolveLocal(obj *Object, sym string, base int, vars map[string]int) (int, bool) {
+	if addr, ok := obj.Labels[sym]; ok {
+		return base + addr, true
+	}
+	addr, ok := vars[sym]
+	return addr, ok
+}
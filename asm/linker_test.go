@@ -0,0 +1,103 @@
+package asm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+// assembleObject assembles a source file into an object
+func assembleObject(path string) (*Object, *Result, error) {
+	f, _ := os.Open(path)
+	defer f.Close()
+	var out bytes.Buffer
+	res, err := Assemble(f, &out, Options{Filename: path, Object: true})
+	if err != nil {
+		return nil, res, err
+	}
+	obj, err := LoadObject(&out)
+	return obj, res, err
+}
+
+func TestLinker(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Object files", func() {
+		obj, _, err := assembleObject("../test/link/Main.asm")
+
+		g.It("Should round trip through the loader", func() {
+			g.Assert(err == nil).IsTrue()
+			g.Assert(obj.Version).Equal(ObjectVersion)
+			g.Assert(obj.Source).Equal("../test/link/Main.asm")
+			g.Assert(len(obj.Code)).Equal(22)
+		})
+		g.It("Should record labels, variables and exports", func() {
+			g.Assert(obj.Labels).Equal(map[string]int{"BACK": 16, "END": 20})
+			g.Assert(obj.Variables).Equal([]string{"result", "count"})
+			g.Assert(len(obj.Exports)).Equal(1)
+			g.Assert(obj.Exports[0]).Equal(Export{"result", "../test/link/Main.asm", 4, 9})
+		})
+		g.It("Should add a fixup for every relocatable symbol", func() {
+			var syms []string
+			for _, f := range obj.Fixups {
+				syms = append(syms, f.Symbol)
+			}
+			g.Assert(syms).Equal([]string{"count", "BACK", "ret", "mult", "result", "END"})
+			g.Assert(obj.Fixups[3]).Equal(Fixup{14, "mult", 0, "../test/link/Main.asm", 20, 6})
+		})
+		g.It("Should reject externs that are also defined", func() {
+			_, res, err := assembleSource(".extern LOOP", "(LOOP)", "@LOOP")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(res.Diagnostics.Items())).Equal(0)
+
+			var out bytes.Buffer
+			src := "(LOOP)\n.extern LOOP\n.extern SP\n.global KBD"
+			res, err = Assemble(bytes.NewReader([]byte(src)), &out, Options{Filename: "Prog.asm", Object: true})
+			g.Assert(err == nil).IsFalse()
+			g.Assert(len(res.Diagnostics.Items())).Equal(3)
+		})
+		g.It("Should reject externs that nothing defines without a link step", func() {
+			_, res, err := assembleSource(".extern mult", "    @mult", "    0;JMP")
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Error()).Equal("Prog.asm:1:9: error: mult is declared with .extern but not defined; " +
+				"externs are only resolved when object files are linked [undefined-symbol]")
+		})
+	})
+
+	g.Describe("Linking", func() {
+		main, _, _ := assembleObject("../test/link/Main.asm")
+		math, _, _ := assembleObject("../test/link/Math.asm")
+
+		g.It("Should relocate labels and resolve exported symbols", func() {
+			var out bytes.Buffer
+			_, err := Link([]*Object{main, math}, &out, LinkOptions{Filename: "Main.hack", Format: FormatMemh})
+			g.Assert(err == nil).IsTrue()
+			words := bytes.Fields(out.Bytes())
+			g.Assert(len(words)).Equal(len(main.Code) + len(math.Code))
+
+			// Main's variables come first, then Math's, which has its own count
+			g.Assert(string(words[0])).Equal("0011")    // @count in Main
+			g.Assert(string(words[12])).Equal("0012")   // @ret, exported by Math
+			g.Assert(string(words[14])).Equal("0016")   // @mult, the start of Math
+			g.Assert(string(words[20])).Equal("0014")   // @END in Main
+			g.Assert(string(words[22+4])).Equal("0013") // @count in Math
+			g.Assert(string(words[22+8])).Equal("0028") // @END in Math, relocated
+		})
+		g.It("Should report undefined and duplicate symbols", func() {
+			undefined, _, _ := assembleObject("../test/link/Undefined.asm")
+			var out bytes.Buffer
+			diags, err := Link([]*Object{main, math, undefined}, &out, LinkOptions{Filename: "Main.hack"})
+			g.Assert(err == nil).IsFalse()
+			g.Assert(out.Len()).Equal(0)
+			items := diags.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Code).Equal(CodeDuplicateSymbol)
+			g.Assert(items[0].Error()).Equal("../test/link/Undefined.asm:2:9: error: mult is also exported by ../test/link/Math.asm [duplicate-symbol]")
+			g.Assert(items[1].Code).Equal(CodeUndefinedSymbol)
+			g.Assert(items[1].Line).Equal(5)
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// listing writes a human readable .lst file: one row per source line with
+// the ROM address and encoding of the instruction on it, then the symbol table
+type listing struct {
+	w *bufio.Writer
+}
+
+func newListing(w io.Writer) *listing {
+	l := &listing{bufio.NewWriter(w)}
+	fmt.Fprintf(l.w, "%5s  %5s  %-16s  %-4s  %s\n", "Line", "Addr", "Binary", "Hex", "Source")
+	return l
+}
+
+// writeLine lists a line of the program. word is the encoding of the
+// instruction on the line, if encoded is true; labels are shown at the
+// address they were assigned.
+func (l *listing) writeLine(ins *instruction, st *SymbolTable, addr int, word uint16, encoded bool) {
+	switch {
+	case encoded && ins.generated:
+		fmt.Fprintf(l.w, "%5d  %5d  %016b  %04X\n", ins.src.Line, addr, word, word)
+	case encoded:
+		fmt.Fprintf(l.w, "%5d  %5d  %016b  %04X  %s\n", ins.src.Line, addr, word, word, ins.src.Text)
+	case ins.cmd.ctype == Equ || ins.cmd.ctype == Set:
+		fmt.Fprintf(l.w, "%5d  %5s  %16s  %4s  %s\n", ins.src.Line, "", "= "+strconv.Itoa(ins.value), "", ins.src.Text)
+	case ins.cmd.ctype == Reserve || ins.cmd.ctype == Data:
+		addr := st.GetAddress(ins.cmd.symbol)
+		fmt.Fprintf(l.w, "%5d  %5s  %16s  %4s  %s\n", ins.src.Line, "", fmt.Sprintf("RAM[%d]", addr), "", ins.src.Text)
+	case ins.cmd.ctype == L:
+		fmt.Fprintf(l.w, "%5d  %5d  %16s  %4s  %s\n", ins.src.Line, st.GetAddress(ins.cmd.symbol), "", "", ins.src.Text)
+	default:
+		fmt.Fprintf(l.w, "%5d  %5s  %16s  %4s  %s\n", ins.src.Line, "", "", "", ins.src.Text)
+	}
+}
+
+// writeSymbols lists the labels with their ROM addresses, the variables
+// with the RAM addresses they were allocated, and the values of any constants
+func (l *listing) writeSymbols(st *SymbolTable) error {
+	fmt.Fprintf(l.w, "\nLabels:\n")
+	for _, sym := range st.Symbols(SymLabel) {
+		fmt.Fprintf(l.w, "  %-32s ROM[%d]\n", sym.Name, sym.Address)
+	}
+	fmt.Fprintf(l.w, "\nVariables:\n")
+	for _, sym := range st.Symbols(SymVariable) {
+		fmt.Fprintf(l.w, "  %-32s RAM[%d]\n", sym.Name, sym.Address)
+	}
+	constants := st.Symbols(SymConstant)
+	if len(constants) > 0 {
+		fmt.Fprintf(l.w, "\nConstants:\n")
+	}
+	for _, sym := range constants {
+		fmt.Fprintf(l.w, "  %-32s = %d\n", sym.Name, sym.Address)
+	}
+	return l.w.Flush()
+}
@@ -0,0 +1,44 @@
+package asm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestListing(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Listing output", func() {
+		g.It("Should match the expected listing for Max", func() {
+			f, _ := os.Open("../test/Max.asm")
+			defer f.Close()
+			var out, lst bytes.Buffer
+			_, err := Assemble(f, &out, Options{Filename: "Max.asm", Listing: &lst})
+			g.Assert(err == nil).IsTrue()
+			expected, _ := ioutil.ReadFile("../test/MaxExpected.lst")
+			g.Assert(lst.String()).Equal(string(expected))
+		})
+		g.It("Should list variables with their RAM addresses", func() {
+			src := "@i\nM=1\n(LOOP)\n@sum\nM=0\n@LOOP\n0;JMP\n"
+			var out, lst bytes.Buffer
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Vars.asm", Listing: &lst})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(strings.Contains(lst.String(), "    3      2                          (LOOP)\n")).IsTrue()
+			g.Assert(strings.HasSuffix(lst.String(), "Labels:\n  "+pad("LOOP")+" ROM[2]\n\nVariables:\n  "+pad("i")+" RAM[16]\n  "+pad("sum")+" RAM[17]\n")).IsTrue()
+		})
+		g.It("Should not write a listing when there are errors", func() {
+			var out, lst bytes.Buffer
+			_, err := Assemble(strings.NewReader("P=D\n"), &out, Options{Filename: "Bad.asm", Listing: &lst})
+			g.Assert(err != nil).IsTrue()
+			g.Assert(lst.Len()).Equal(0)
+		})
+	})
+}
+
+func pad(s string) string {
+	return s + strings.Repeat(" ", 32-len(s))
+}
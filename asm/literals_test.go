@@ -0,0 +1,70 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestLiterals(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("A-instruction range", func() {
+		cases := []struct {
+			operand string
+			message string
+		}{
+			{"32768", "32768 does not fit in 15 bits; A-instructions hold 0 to 32767"},
+			{"99999999999999999999", "99999999999999999999 does not fit in 15 bits; A-instructions hold 0 to 32767"},
+			{"-40000", "-40000 does not fit in 15 bits; A-instructions hold 0 to 32767"},
+			{"-1", "-1 is negative; A-instructions hold 0 to 32767, so load 0 and use A=!A"},
+			{"-32768", "-32768 is negative; A-instructions hold 0 to 32767, so load 32767 and use A=!A"},
+		}
+		for _, c := range cases {
+			c := c
+			g.It("Should reject @"+c.operand, func() {
+				_, res, err := assembleSource("  @" + c.operand)
+				g.Assert(err == nil).IsFalse()
+				items := res.Diagnostics.Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Column).Equal(4)
+				g.Assert(items[0].Length).Equal(len(c.operand))
+				g.Assert(items[0].Code).Equal(CodeInvalidConstant)
+				g.Assert(items[0].Message).Equal(c.message)
+			})
+		}
+		g.It("Should accept the bounds", func() {
+			out, _, err := assembleSource("@0", "@32767", "@+5")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal("0000000000000000\n0111111111111111\n0000000000000101\n")
+		})
+	})
+
+	g.Describe("Negative literal expansion", func() {
+		g.It("Should load negative literals with A=!A", func() {
+			var out bytes.Buffer
+			src := "    @-5 // five below zero\n(NEXT)\n    @NEXT"
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", ExpandNegatives: true})
+			g.Assert(err == nil).IsTrue()
+			expected, _, _ := assembleSource("@4", "A=!A", "@2")
+			g.Assert(out.String()).Equal(expected)
+		})
+		g.It("Should show the source as written in listings", func() {
+			var out, lst bytes.Buffer
+			src := "    @-5 // five below zero\n    D=A"
+			_, err := Assemble(strings.NewReader(src), &out, Options{Filename: "Prog.asm", ExpandNegatives: true, Listing: &lst})
+			g.Assert(err == nil).IsTrue()
+			lines := strings.Split(lst.String(), "\n")
+			g.Assert(lines[1]).Equal("    1      0  0000000000000100  0004      @-5 // five below zero")
+			g.Assert(lines[2]).Equal("    1      1  1110110001100000  EC60")
+			g.Assert(lines[3]).Equal("    2      2  1110110000010000  EC10      D=A")
+		})
+		g.It("Should still reject values out of range", func() {
+			var out bytes.Buffer
+			res, err := Assemble(strings.NewReader("@-40000"), &out, Options{Filename: "Prog.asm", ExpandNegatives: true})
+			g.Assert(err == nil).IsFalse()
+			g.Assert(res.Diagnostics.Items()[0].Code).Equal(CodeInvalidConstant)
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package asm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codes for programs that do not fit in the memory of the Hack computer
+const (
+	CodeROMOverflow = "rom-overflow"
+	CodeRAMOverflow = "ram-overflow"
+)
+
+// ROMSize is the number of words in the Hack instruction memory
+const ROMSize = 32768
+
+// firstVariable is the RAM address of the first variable
+const firstVariable = 16
+
+// RAMLimit is a RAM address that variables should not be allocated at or
+// beyond, such as the start of the stack. Crossing it is reported with the
+// given severity.
+type RAMLimit struct {
+	Name     string
+	Address  int
+	Severity Severity
+}
+
+// DefaultRAMLimits warns when variables grow out of the static segment into
+// the stack, as laid out by the Hack VM, and fails when they reach the
+// memory mapped screen
+var DefaultRAMLimits = []RAMLimit{
+	{"stack", 256, SeverityWarning},
+	{"screen", 16384, SeverityError},
+}
+
+// ParseRAMLimits reads RAM limits written as name=address[:severity] and
+// separated by commas, such as stack=256:warning,screen=16384:error. The
+// severity defaults to error. The string none means no limits.
+func ParseRAMLimits(s string) ([]RAMLimit, error) {
+	limits := []RAMLimit{}
+	if s == "none" {
+		return limits, nil
+	}
+	for _, spec := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(spec), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%s is not a valid RAM limit; expected name=address[:severity]", spec)
+		}
+		value, sev := kv[1], SeverityError
+		if i := strings.Index(value, ":"); i != -1 {
+			s := EnumValFromString(SeverityStrings, value[i+1:])
+			if s == -1 || Severity(s) == SeverityNote {
+				return nil, fmt.Errorf("%s is not a valid severity; expected warning or error", value[i+1:])
+			}
+			value, sev = value[:i], Severity(s)
+		}
+		addr, err := strconv.Atoi(value)
+		if err != nil || addr <= firstVariable || addr > 0x8000 {
+			return nil, fmt.Errorf("%s is not a valid RAM address for limit %s", value, kv[0])
+		}
+		limits = append(limits, RAMLimit{kv[0], addr, sev})
+	}
+	return limits, nil
+}
+
+// Usage summarizes how much of the memory of the Hack computer a program uses
+type Usage struct {
+	// ROMWords is the number of instructions written to ROM
+	ROMWords int
+	// Variables is the number of variables and data regions
+	Variables int
+	// RAMWords is the number of RAM words they take up, from address 16
+	RAMWords int
+}
+
+func newUsage(count int, st *SymbolTable) Usage {
+	return Usage{count, len(st.Symbols(SymVariable)), st.NextRAM() - firstVariable}
+}
+
+func (u Usage) String() string {
+	s := fmt.Sprintf("ROM: %d of %d words (%.1f%%), RAM: %d variable(s)", u.ROMWords, ROMSize,
+		float64(u.ROMWords)*100/ROMSize, u.Variables)
+	if u.RAMWords > 0 {
+		s += fmt.Sprintf(" in RAM[%d..%d]", firstVariable, firstVariable+u.RAMWords-1)
+	}
+	return s
+}
+
+// ramLimits returns the limits to check, in address order
+func ramLimits(opts Options) []RAMLimit {
+	limits := opts.RAMLimits
+	if limits == nil {
+		limits = DefaultRAMLimits
+	}
+	limits = append([]RAMLimit{}, limits...)
+	sort.SliceStable(limits, func(i, j int) bool { return limits[i].Address < limits[j].Address })
+	return limits
+}
+
+// checkRAM reports each RAM limit that the variables allocated so far have
+// crossed, at the line that allocated the variable crossing it
+func (asm *assembly) checkRAM(ins *instruction) {
+	for len(asm.limits) > 0 && asm.st.NextRAM() > asm.limits[0].Address {
+		limit := asm.limits[0]
+		asm.limits = asm.limits[1:]
+		sym := ins.res.sym
+		if ctype := ins.cmd.ctype; ctype == Reserve || ctype == Data || ctype == Global {
+			sym = ins.cmd.symbol
+		}
+		col, length := labelColumn(ins.src.Text)
+		asm.diags.Add(ins.newDiagnostic(limit.Severity, col, length, CodeRAMOverflow,
+			fmt.Sprintf("%s takes RAM[%d], past the start of the %s at RAM[%d]",
+				sym, asm.st.NextRAM()-1, limit.Name, limit.Address)))
+	}
+}
+
+// checkROM reports the first instruction that does not fit in ROM
+func (asm *assembly) checkROM(ins *instruction) {
+	if asm.count == ROMSize {
+		col, length := labelColumn(ins.src.Text)
+		asm.report(ins, col, length, CodeROMOverflow, "this instruction is at ROM[%d], past the end of the %d-word ROM",
+			asm.count, ROMSize)
+	}
+}
@@ -0,0 +1,73 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestMemory(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Memory capacity", func() {
+		g.It("Should report the first instruction past the end of ROM", func() {
+			lines := make([]string, ROMSize+2)
+			for i := range lines {
+				lines[i] = "@0"
+			}
+			_, res, err := assembleSource(lines...)
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Code).Equal(CodeROMOverflow)
+			g.Assert(items[0].Line).Equal(ROMSize + 1)
+		})
+		g.It("Should warn when variables reach the stack", func() {
+			_, res, err := assembleSource(".data buf 239", "    @x", "    @y")
+			g.Assert(err == nil).IsTrue()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Severity).Equal(SeverityWarning)
+			g.Assert(items[0].Line).Equal(3)
+			g.Assert(items[0].Message).Equal("y takes RAM[256], past the start of the stack at RAM[256]")
+		})
+		g.It("Should fail when variables reach the screen", func() {
+			_, res, err := assembleSource(".data buf 16400")
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[1].Severity).Equal(SeverityError)
+			g.Assert(items[1].Message).Equal("buf takes RAM[16415], past the start of the screen at RAM[16384]")
+		})
+		g.It("Should check custom limits", func() {
+			var out bytes.Buffer
+			limits := []RAMLimit{{"heap", 18, SeverityError}}
+			res, err := Assemble(strings.NewReader("@a\n@b\n@c"), &out, Options{Filename: "Prog.asm", RAMLimits: limits})
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Line).Equal(3)
+			_, err = Assemble(strings.NewReader(".data buf 20000"), &out, Options{Filename: "Prog.asm", RAMLimits: []RAMLimit{}})
+			g.Assert(err == nil).IsTrue()
+		})
+		g.It("Should parse RAM limits", func() {
+			limits, err := ParseRAMLimits("stack=256:warning, heap=2048")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(limits).Equal([]RAMLimit{{"stack", 256, SeverityWarning}, {"heap", 2048, SeverityError}})
+			limits, err = ParseRAMLimits("none")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(limits)).Equal(0)
+			for _, s := range []string{"stack", "=256", "stack=x", "stack=8", "stack=256:note", "stack=256:fatal"} {
+				_, err = ParseRAMLimits(s)
+				g.Assert(err == nil).IsFalse()
+			}
+		})
+		g.It("Should summarize memory usage", func() {
+			_, res, _ := assembleSource(".data buf 4", "    @x", "    @buf", "    D=A")
+			g.Assert(res.Usage).Equal(Usage{3, 2, 5})
+			g.Assert(res.Usage.String()).Equal("ROM: 3 of 32768 words (0.0%), RAM: 2 variable(s) in RAM[16..20]")
+			g.Assert(Usage{0, 0, 0}.String()).Equal("ROM: 0 of 32768 words (0.0%), RAM: 0 variable(s)")
+		})
+	})
+}
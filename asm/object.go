@@ -0,0 +1,110 @@
+package asm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ObjectVersion is the version of the object file schema written by the assembler
+const ObjectVersion = 1
+
+// Object is a relocatable object file: the code of a single source file,
+// assembled as if it were loaded at ROM address 0, together with what the
+// linker needs to place it in a larger program. It is written as JSON:
+//
+//	{
+//	  "version": 1,
+//	  "source": "Math.asm",
+//	  "code": [0, 60432, ...],
+//	  "labels": {"Math.multiply": 0, "LOOP": 4, ...},
+//	  "variables": ["product", ...],
+//	  "exports": [{"name": "Math.multiply", "file": "Math.asm", "line": 3, "column": 1}],
+//	  "fixups": [{"address": 0, "symbol": "product", "file": "Math.asm", "line": 5, "column": 2}]
+//	}
+//
+// Labels are private to the object unless they are named by .global, and so
+// are variables, which the linker allocates separately for each object.
+// Every A-instruction that loads a label, a variable or a symbol declared
+// with .extern has a fixup, and the linker replaces the word at its address
+// with the symbol's final address plus the fixup's addend.
+type Object struct {
+	Version   int            `json:"version"`
+	Source    string         `json:"source"`
+	Code      []uint16       `json:"code"`
+	Labels    map[string]int `json:"labels"`
+	Variables []string       `json:"variables"`
+	Exports   []Export       `json:"exports"`
+	Fixups    []Fixup        `json:"fixups"`
+}
+
+// Export is a symbol named by a .global directive
+type Export struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// Fixup is an A-instruction whose value is only known once the object is linked
+type Fixup struct {
+	Address int    `json:"address"`
+	Symbol  string `json:"symbol"`
+	Addend  int    `json:"addend,omitempty"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func newObject(source string) *Object {
+	return &Object{ObjectVersion, source, []uint16{}, map[string]int{}, []string{}, []Export{}, []Fixup{}}
+}
+
+// LoadObject reads an object file written by the assembler
+func LoadObject(r io.Reader) (*Object, error) {
+	var obj Object
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj.Version != ObjectVersion {
+		return nil, fmt.Errorf("unsupported object file version %d", obj.Version)
+	}
+	for _, f := range obj.Fixups {
+		if f.Address < 0 || f.Address >= len(obj.Code) {
+			return nil, fmt.Errorf("fixup for %s is outside the code at address %d", f.Symbol, f.Address)
+		}
+	}
+	return &obj, nil
+}
+
+// Write encodes the object as indented JSON
+func (obj *Object) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(obj)
+}
+
+// addSymbols records the labels of the symbol table, and the variables in the
+// order they were allocated
+func (obj *Object) addSymbols(st *SymbolTable) {
+	for _, sym := range st.Symbols(SymLabel) {
+		obj.Labels[sym.Name] = sym.Address
+	}
+	for _, sym := range st.Symbols(SymVariable) {
+		obj.Variables = append(obj.Variables, sym.Name)
+	}
+}
+
+// objectWriter collects the assembled words into an object's code
+type objectWriter struct {
+	obj *Object
+}
+
+func (ow *objectWriter) WriteWord(word uint16) error {
+	ow.obj.Code = append(ow.obj.Code, word)
+	return nil
+}
+
+func (ow *objectWriter) Close() error {
+	return nil
+}
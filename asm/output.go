@@ -0,0 +1,240 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Format is an integer enum type
+type Format int
+
+// Enum for the file formats the assembled ROM image can be written in:
+// FormatHack is the textual .hack format, one 16-digit binary word per line
+// FormatBinary is raw big-endian binary, two bytes per word
+// FormatMemh is a Verilog $readmemh file, one 4-digit hex word per line
+// FormatMemb is a Verilog $readmemb file, one 16-digit binary word per line
+// FormatIntelHex is Intel HEX records with big-endian words at byte addresses
+// FormatLogisim is a Logisim "v2.0 raw" memory image
+// FormatCOE is a Xilinx memory initialization (.coe) file
+// FormatMIF is an Altera/Intel memory initialization (.mif) file
+const (
+	FormatHack Format = iota
+	FormatBinary
+	FormatMemh
+	FormatMemb
+	FormatIntelHex
+	FormatLogisim
+	FormatCOE
+	FormatMIF
+)
+
+// FormatStrings enables converting a Format to and from its string representation
+var FormatStrings = []string{"hack", "bin", "memh", "memb", "ihex", "logisim", "coe", "mif"}
+
+// FormatExtensions holds the conventional file extension for each Format
+var FormatExtensions = []string{".hack", ".bin", ".memh", ".memb", ".hex", ".logisim", ".coe", ".mif"}
+
+func (f Format) String() string {
+	return FormatStrings[f]
+}
+
+// OutputWriter writes assembled words in a particular file format. Close
+// must be called after the last word to write any trailer; it does not
+// close the underlying writer.
+type OutputWriter interface {
+	WriteWord(word uint16) error
+	Close() error
+}
+
+// NewOutputWriter is a factory that creates an OutputWriter for the given format
+func NewOutputWriter(f Format, w io.Writer) OutputWriter {
+	bw := bufio.NewWriter(w)
+	switch f {
+	case FormatBinary:
+		return &binaryWriter{bw}
+	case FormatMemh:
+		return &lineWriter{bw, "%04x\n"}
+	case FormatMemb:
+		return &lineWriter{bw, "%016b\n"}
+	case FormatIntelHex:
+		return &intelHexWriter{w: bw}
+	case FormatLogisim:
+		return &logisimWriter{w: bw}
+	case FormatCOE:
+		return &coeWriter{w: bw}
+	case FormatMIF:
+		return &mifWriter{w: bw}
+	default:
+		return &lineWriter{bw, "%016b\n"}
+	}
+}
+
+// lineWriter writes each word on its own line in a fixed format
+type lineWriter struct {
+	w      *bufio.Writer
+	format string
+}
+
+func (lw *lineWriter) WriteWord(word uint16) error {
+	_, err := fmt.Fprintf(lw.w, lw.format, word)
+	return err
+}
+
+func (lw *lineWriter) Close() error {
+	return lw.w.Flush()
+}
+
+// binaryWriter writes each word as two bytes, most significant first
+type binaryWriter struct {
+	w *bufio.Writer
+}
+
+func (bw *binaryWriter) WriteWord(word uint16) error {
+	_, err := bw.w.Write([]byte{byte(word >> 8), byte(word)})
+	return err
+}
+
+func (bw *binaryWriter) Close() error {
+	return bw.w.Flush()
+}
+
+// intelHexWriter writes data records of up to 16 bytes, followed by an
+// end-of-file record. Word n is stored big-endian at byte address 2n.
+type intelHexWriter struct {
+	w    *bufio.Writer
+	addr int
+	data []byte
+}
+
+func (hw *intelHexWriter) WriteWord(word uint16) error {
+	hw.data = append(hw.data, byte(word>>8), byte(word))
+	if len(hw.data) == 16 {
+		return hw.flushRecord()
+	}
+	return nil
+}
+
+func (hw *intelHexWriter) flushRecord() error {
+	if len(hw.data) == 0 {
+		return nil
+	}
+	err := writeHexRecord(hw.w, hw.addr, 0x00, hw.data)
+	hw.addr += len(hw.data)
+	hw.data = hw.data[:0]
+	return err
+}
+
+func (hw *intelHexWriter) Close() error {
+	if err := hw.flushRecord(); err != nil {
+		return err
+	}
+	if err := writeHexRecord(hw.w, 0, 0x01, nil); err != nil {
+		return err
+	}
+	return hw.w.Flush()
+}
+
+// writeHexRecord writes a single Intel HEX record with its checksum, which is
+// the two's complement of the sum of every other byte in the record
+func writeHexRecord(w io.Writer, addr int, kind byte, data []byte) error {
+	sum := byte(len(data)) + byte(addr>>8) + byte(addr) + kind
+	line := fmt.Sprintf(":%02X%04X%02X", len(data), addr&0xffff, kind)
+	for _, b := range data {
+		line += fmt.Sprintf("%02X", b)
+		sum += b
+	}
+	_, err := fmt.Fprintf(w, "%s%02X\n", line, byte(-sum))
+	return err
+}
+
+// logisimWriter writes a "v2.0 raw" image, eight lowercase hex words per line
+type logisimWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (lw *logisimWriter) WriteWord(word uint16) error {
+	var err error
+	switch {
+	case lw.count == 0:
+		_, err = fmt.Fprintf(lw.w, "v2.0 raw\n%x", word)
+	case lw.count%8 == 0:
+		_, err = fmt.Fprintf(lw.w, "\n%x", word)
+	default:
+		_, err = fmt.Fprintf(lw.w, " %x", word)
+	}
+	lw.count++
+	return err
+}
+
+func (lw *logisimWriter) Close() error {
+	if lw.count == 0 {
+		lw.w.WriteString("v2.0 raw")
+	}
+	lw.w.WriteString("\n")
+	return lw.w.Flush()
+}
+
+// coeWriter writes a hex memory_initialization_vector. Entries are separated
+// by commas and the last one is terminated by a semicolon, so each word is
+// only written once the next one (or Close) shows whether it was the last.
+type coeWriter struct {
+	w       *bufio.Writer
+	count   int
+	pending uint16
+}
+
+func (cw *coeWriter) WriteWord(word uint16) error {
+	var err error
+	if cw.count == 0 {
+		_, err = cw.w.WriteString("memory_initialization_radix=16;\nmemory_initialization_vector=\n")
+	} else {
+		_, err = fmt.Fprintf(cw.w, "%04x,\n", cw.pending)
+	}
+	cw.pending = word
+	cw.count++
+	return err
+}
+
+func (cw *coeWriter) Close() error {
+	if cw.count == 0 {
+		cw.w.WriteString("memory_initialization_radix=16;\nmemory_initialization_vector=0000;\n")
+	} else {
+		fmt.Fprintf(cw.w, "%04x;\n", cw.pending)
+	}
+	return cw.w.Flush()
+}
+
+// mifWriter writes one binary entry per word, and fills the rest of the ROM with zeros
+type mifWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+// mifDepth is the number of words in the Hack ROM
+const mifDepth = 32768
+
+func (mw *mifWriter) WriteWord(word uint16) error {
+	if mw.count == 0 {
+		mw.writeHeader()
+	}
+	_, err := fmt.Fprintf(mw.w, "\t%d : %016b;\n", mw.count, word)
+	mw.count++
+	return err
+}
+
+func (mw *mifWriter) writeHeader() {
+	fmt.Fprintf(mw.w, "WIDTH=16;\nDEPTH=%d;\n\nADDRESS_RADIX=UNS;\nDATA_RADIX=BIN;\n\nCONTENT BEGIN\n", mifDepth)
+}
+
+func (mw *mifWriter) Close() error {
+	if mw.count == 0 {
+		mw.writeHeader()
+	}
+	if mw.count < mifDepth {
+		fmt.Fprintf(mw.w, "\t[%d..%d] : %016b;\n", mw.count, mifDepth-1, 0)
+	}
+	mw.w.WriteString("END;\n")
+	return mw.w.Flush()
+}
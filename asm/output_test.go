@@ -0,0 +1,63 @@
+package asm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestOutput(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Output formats", func() {
+		golden := map[Format]string{
+			FormatHack:     "../test/MaxExpected.hack",
+			FormatBinary:   "../test/golden/Max.bin",
+			FormatMemh:     "../test/golden/Max.memh",
+			FormatMemb:     "../test/golden/Max.memb",
+			FormatIntelHex: "../test/golden/Max.hex",
+			FormatLogisim:  "../test/golden/Max.logisim",
+			FormatCOE:      "../test/golden/Max.coe",
+			FormatMIF:      "../test/golden/Max.mif",
+		}
+		for format, path := range golden {
+			format, path := format, path
+			g.It("Should match the golden "+format.String()+" file", func() {
+				f, _ := os.Open("../test/Max.asm")
+				defer f.Close()
+				var out bytes.Buffer
+				_, err := Assemble(f, &out, Options{Filename: "Max.asm", Format: format})
+				g.Assert(err == nil).IsTrue()
+				expected, _ := ioutil.ReadFile(path)
+				g.Assert(bytes.Equal(out.Bytes(), expected)).IsTrue()
+			})
+		}
+
+		g.It("Should write only the trailers for an empty program", func() {
+			expected := map[Format]string{
+				FormatHack:     "",
+				FormatBinary:   "",
+				FormatIntelHex: ":00000001FF\n",
+				FormatLogisim:  "v2.0 raw\n",
+				FormatCOE:      "memory_initialization_radix=16;\nmemory_initialization_vector=0000;\n",
+			}
+			for format, exp := range expected {
+				var out bytes.Buffer
+				w := NewOutputWriter(format, &out)
+				g.Assert(w.Close() == nil).IsTrue()
+				g.Assert(out.String()).Equal(exp)
+			}
+		})
+		g.It("Should split Intel HEX records every 16 bytes", func() {
+			var out bytes.Buffer
+			w := NewOutputWriter(FormatIntelHex, &out)
+			for i := 0; i < 9; i++ {
+				w.WriteWord(0x1234)
+			}
+			w.Close()
+			g.Assert(out.String()).Equal(":1000000012341234123412341234123412341234C0\n:020010001234A8\n:00000001FF\n")
+		})
+	})
+}
@@ -0,0 +1,439 @@
+package asm
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Command represents a single assembly command
+type Command struct {
+	ctype  CommandType
+	comp   CompMnemonic
+	jump   JumpMnemonic
+	mloc   MemoryLocation
+	symbol string
+}
+
+// Parser is the main object that processes the input file line by line
+type Parser struct {
+	file            string
+	st              *SymbolTable
+	lines           []SourceLine
+	next            int
+	scope           *scope
+	module          string
+	expansion       *Expansion
+	operand         *operand
+	res             resolution
+	undefined       *symbolRef
+	value           int
+	words           []uint16
+	expandNegatives bool
+	inverted        bool
+	currentCommand  Command
+	hasMoreCommands bool
+	line            int
+	text            string
+	diag            *Diagnostic
+}
+
+// NewParser is a factory that creates a parser instance for the given source.
+// The file name is only used to label diagnostics. Macros are not expanded.
+func NewParser(r io.Reader, file string, st *SymbolTable) Parser {
+	lines, _ := readLines(r, file)
+	return newLineParser(lines, file, st, nil)
+}
+
+// newLineParser creates a parser for source that has already been split into
+// lines, belonging to the given modules if several files are assembled together
+func newLineParser(lines []SourceLine, file string, st *SymbolTable, modules map[string]*module) Parser {
+	return Parser{file, st, lines, 0, newScope(modules), "", nil, nil, resolution{}, nil, 0, nil, false, false, Command{}, true, 0, "", nil}
+}
+
+// HasMoreCommands indicates whether the entire input file has been processed
+func (p *Parser) HasMoreCommands() bool {
+	return p.hasMoreCommands
+}
+
+// CurrentCommand returns the parsed command for the current line if it exists
+func (p *Parser) CurrentCommand() Command {
+	return p.currentCommand
+}
+
+// CommandType returns the type of the current command if it exists
+func (p *Parser) CommandType() CommandType {
+	return p.currentCommand.ctype
+}
+
+// LineNumber returns the 1-based number of the current line in the input file
+func (p *Parser) LineNumber() int {
+	return p.line
+}
+
+// Diagnostic returns the problem found while parsing the current line,
+// or nil if the line was parsed successfully
+func (p *Parser) Diagnostic() *Diagnostic {
+	return p.diag
+}
+
+// Advance moves one line forward in the input file. If the line cannot
+// be parsed, the current command is a CmdNull and Diagnostic describes why.
+func (p *Parser) Advance(novars bool) {
+	p.hasMoreCommands = p.next < len(p.lines)
+	if !p.hasMoreCommands {
+		return
+	}
+	src := p.lines[p.next]
+	p.next++
+	p.file, p.line, p.text, p.module, p.expansion = src.File, src.Line, src.Text, src.Module, src.Expansion
+	p.diag, p.operand, p.res, p.undefined, p.value, p.words, p.inverted = nil, nil, resolution{}, nil, 0, nil, false
+	p.hasMoreCommands = true
+	cmd, err := p.parseLine(p.text, novars)
+	if err != nil {
+		d := lineError(p.source(), err)
+		p.diag = &d
+		cmd = Command{CmdNull, Comp0, JmpNull, LocNull, ""}
+	}
+	p.currentCommand = cmd
+}
+
+// instruction returns the current line as parsed, at the given index in the program
+func (p *Parser) instruction(index int) instruction {
+	return instruction{p.source(), index, p.currentCommand, p.diag, p.operand, p.undefined, p.value, p.words, p.res, false}
+}
+
+// lineError converts an error found on a line into a diagnostic, which points
+// at the whole line unless the error is a syntaxError with a position
+func lineError(l SourceLine, err error) Diagnostic {
+	d := newLineDiagnostic(l, SeverityError, 1, len(l.Text), CodeInvalidCommand, err.Error())
+	if se, ok := err.(*syntaxError); ok {
+		d.Column, d.Length, d.Code = se.column, se.length, se.code
+	}
+	return d
+}
+
+// newDiagnostic creates a diagnostic pointing at the given column of the current line
+func (p *Parser) newDiagnostic(sev Severity, column int, length int, code string, msg string) Diagnostic {
+	return newLineDiagnostic(p.source(), sev, column, length, code, msg)
+}
+
+// source returns the current line
+func (p *Parser) source() SourceLine {
+	return SourceLine{p.text, p.file, p.line, p.module, p.expansion}
+}
+
+func (p *Parser) parseLine(line string, novars bool) (Command, error) {
+	if strings.HasPrefix(line, CommentToken) {
+		return Command{Comment, Comp0, JmpNull, LocNull, line[2:]}, nil
+	}
+	raw := line
+	line = stripInlineComments(line)
+	cmd, err := p.parseInstruction(line, novars)
+	// Make columns relative to the start of the raw line
+	shift := strings.Index(raw, line)
+	if se, ok := err.(*syntaxError); ok {
+		se.column += shift
+	}
+	if p.operand != nil {
+		p.operand.column += shift
+	}
+	return cmd, err
+}
+
+func (p *Parser) parseInstruction(line string, novars bool) (Command, error) {
+	if line == "" {
+		return Command{CmdNull, Comp0, JmpNull, LocNull, ""}, nil
+	}
+	if strings.HasPrefix(line, LabelToken) {
+		return p.parseLabelDefinition(line)
+	}
+	if n, ok := numericLabel(strings.TrimSuffix(line, ":")); ok && strings.HasSuffix(line, ":") {
+		return p.parseLabel(n, 0)
+	}
+	if strings.HasPrefix(line, ".") {
+		return p.parseDirective(line)
+	}
+	if strings.HasPrefix(line, ACmdToken) {
+		return p.parseAInstruction(line, novars)
+	}
+	code, cols, err := compact(line)
+	if err != nil {
+		return Command{}, err
+	}
+	cmd, err := p.parseCInstruction(code)
+	return cmd, expandColumns(err, cols)
+}
+
+// parseDirective parses a line that starts with the name of a directive
+func (p *Parser) parseDirective(line string) (Command, error) {
+	switch token := strings.Fields(line)[0]; token {
+	case GlobalToken, ExternToken:
+		return p.parseLinkageDirective(line)
+	case EquToken, DefineToken, SetToken:
+		return p.parseConstant(line)
+	case DataToken, WordToken, StringToken:
+		return p.parseData(line)
+	default:
+		return Command{}, newSyntaxError(1, len(token), CodeInvalidCommand, "%s is not a known directive", token)
+	}
+}
+
+// parseLabelDefinition parses a label such as (LOOP). Blanks are allowed
+// inside the parentheses, but nothing may follow them.
+func (p *Parser) parseLabelDefinition(line string) (Command, error) {
+	end := strings.Index(line, ")")
+	if end == -1 {
+		return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "label %s is missing a closing )", line)
+	}
+	if rest := strings.TrimSpace(line[end+1:]); rest != "" {
+		return Command{}, newSyntaxError(strings.LastIndex(line, rest)+1, len(rest), CodeInvalidCommand, "unexpected %s after label", rest)
+	}
+	name := strings.TrimSpace(line[1:end])
+	if name == "" {
+		return Command{}, newSyntaxError(1, end+1, CodeInvalidCommand, "label has no name")
+	}
+	offset := strings.Index(line, name)
+	if err := checkSymbol(name, offset+1); err != nil {
+		return Command{}, err
+	}
+	return p.parseLabel(name, offset)
+}
+
+// parseLiteral is strconv.Atoi, without the error it allocates for the
+// symbols that most A-instructions hold
+func parseLiteral(sym string) (int, error) {
+	if digits := strings.TrimLeft(sym, "+-"); digits == "" || digits[0] < '0' || digits[0] > '9' {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.Atoi(sym)
+}
+
+// parseLabel qualifies the name of a label that starts at the given offset in the line
+func (p *Parser) parseLabel(name string, offset int) (Command, error) {
+	sym, err := p.scope.define(name, p.source())
+	if se, ok := err.(*syntaxError); ok {
+		se.column += offset
+		return Command{}, se
+	}
+	return Command{L, Comp0, JmpNull, LocNull, sym}, nil
+}
+
+func (p *Parser) parseAInstruction(line string, novars bool) (Command, error) {
+	sym := strings.TrimLeft(line[1:], " \t")
+	offset := len(line) - len(sym)
+	// If symbol is an integer literal, we can just return it once its range is checked
+	if n, err := parseLiteral(sym); err == nil || errors.Is(err, strconv.ErrRange) {
+		switch {
+		case err != nil || n > 0x7fff || n < -0x8000:
+			return Command{}, newSyntaxError(offset+1, len(sym), CodeInvalidConstant, "%s does not fit in 15 bits; A-instructions hold 0 to 32767", sym)
+		case n < 0 && p.expandNegatives:
+			// !n is -n-1 in two's complement, so A=!A after loading it
+			// leaves n in A
+			p.inverted = true
+			return Command{A, Comp0, JmpNull, LocNull, strconv.Itoa(^n)}, nil
+		case n < 0:
+			return Command{}, newSyntaxError(offset+1, len(sym), CodeInvalidConstant,
+				"%s is negative; A-instructions hold 0 to 32767, so load %d and use A=!A", sym, ^n)
+		}
+		return Command{A, Comp0, JmpNull, LocNull, strconv.Itoa(n)}, nil
+	}
+	// Local, numeric and module-private names are qualified before lookup;
+	// whether they exist can only be checked once every label is known
+	op, err := p.scope.newOperand(sym, offset+1, p.source())
+	if err == nil {
+		p.operand = op
+		p.res, err = p.scope.resolveOperand(op, p.source(), p.st, !novars)
+	}
+	if se, ok := err.(*syntaxError); ok {
+		se.column += offset
+	}
+	if err != nil {
+		return Command{}, err
+	}
+	return Command{A, Comp0, JmpNull, LocNull, strconv.Itoa(p.res.value)}, nil
+}
+
+// parseConstant parses a .equ, .define or .set directive, such as
+// .equ ROWS 256 or .set WIDTH, ROWS/8. The value may be an expression, which
+// can only refer to predefined symbols and the constants defined before it.
+func (p *Parser) parseConstant(line string) (Command, error) {
+	token := strings.Fields(line)[0]
+	ctype := Equ
+	if token == SetToken {
+		ctype = Set
+	}
+	name, expr, err := splitDirective(line, token)
+	if err != nil {
+		return Command{}, err
+	}
+	if name == "" || expr == "" {
+		return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "%s takes a name and a value", token)
+	}
+	v, err := evalExpr(expr, p.constantLookup)
+	if err != nil {
+		if se, ok := err.(*syntaxError); ok {
+			se.column += len(line) - len(expr)
+		}
+		return Command{}, err
+	}
+	sym := p.scope.private(name, p.module)
+	p.scope.constants[sym] = true
+	p.value = v.n
+	return Command{ctype, Comp0, JmpNull, LocNull, sym}, nil
+}
+
+// constantLookup returns the value of an identifier in the expression of a
+// directive, which must be a predefined symbol or a constant defined earlier
+func (p *Parser) constantLookup(ident string, pos int) (exprValue, error) {
+	sym := p.scope.private(ident, p.module)
+	kind := p.st.Kind(sym)
+	switch {
+	case kind == SymPredefined || p.scope.constants[sym]:
+		return exprValue{p.st.GetAddress(sym), "", false}, nil
+	case kind == SymNull:
+		p.undefined = &symbolRef{ident, sym, p.scope.global}
+		return exprValue{}, newSyntaxError(pos+1, len(ident), CodeUndefinedSymbol, "%s is not defined", ident)
+	case kind == SymConstant:
+		return exprValue{}, newSyntaxError(pos+1, len(ident), CodeUndefinedSymbol, "%s is not defined until a later line", ident)
+	}
+	return exprValue{}, newSyntaxError(pos+1, len(ident), CodeInvalidExpression, "%s is a %s, not a constant", ident, SymbolKindStrings[kind])
+}
+
+// splitDirective splits the operands of a directive that defines a symbol
+// into its name and the rest of the line, which may be separated by a comma.
+// The name is empty if there is none, and an error if it is not a valid
+// global symbol.
+func splitDirective(line string, token string) (string, string, error) {
+	rest := strings.TrimLeft(line[len(token):], " \t")
+	name := leadingWord(rest)
+	tail := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest[len(name):]), ","))
+	if name == "" {
+		return "", tail, nil
+	}
+	col := len(line) - len(rest) + 1
+	if strings.HasPrefix(name, LocalLabelToken) {
+		return "", "", newSyntaxError(col, len(name), CodeInvalidSymbol, "%s cannot be local", name)
+	}
+	return name, tail, checkSymbol(name, col)
+}
+
+// parseLinkageDirective parses a .global or .extern directive naming a single symbol
+func (p *Parser) parseLinkageDirective(line string) (Command, error) {
+	fields := strings.Fields(line)
+	ctype, token := Global, GlobalToken
+	if fields[0] == ExternToken {
+		ctype, token = Extern, ExternToken
+	}
+	if len(fields) != 2 {
+		return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "%s takes exactly one symbol", token)
+	}
+	if err := checkSymbol(fields[1], strings.LastIndex(line, fields[1])+1); err != nil {
+		return Command{}, err
+	}
+	return Command{ctype, Comp0, JmpNull, LocNull, fields[1]}, nil
+}
+
+func (p *Parser) parseCInstruction(line string) (Command, error) {
+	if !strings.ContainsAny(line, "=;") {
+		// A bare comp with neither dest nor jump is still a valid C instruction
+		if comp := EnumValFromString(CompStrings, line); comp != -1 {
+			return Command{C, CompMnemonic(comp), JmpNull, LocNull, ""}, nil
+		}
+		return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "%s is not a valid instruction", line)
+	}
+
+	// A C instruction has the general form dest=comp;jump, where either
+	// the dest or the jump (but not both) may be omitted. Offsets are
+	// tracked so that errors can point at the offending field.
+	mloc := LocNull
+	compStr, compCol := line, 1
+	if eq := strings.Index(line, "="); eq != -1 {
+		destStr := line[:eq]
+		compStr, compCol = line[eq+1:], eq+2
+		if destStr == "" || compStr == "" {
+			return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "%s is not a valid C command", line)
+		}
+		loc := EnumValFromString(MemoryLocationStrings, destStr)
+		if loc == -1 || MemoryLocation(loc) == LocNull {
+			return Command{}, newSyntaxError(1, len(destStr), CodeInvalidDest, "%s is not a valid memory location", destStr)
+		}
+		mloc = MemoryLocation(loc)
+	}
+
+	jmp := JmpNull
+	if semi := strings.Index(compStr, ";"); semi != -1 {
+		jmpStr := compStr[semi+1:]
+		compStr = compStr[:semi]
+		if compStr == "" || jmpStr == "" {
+			return Command{}, newSyntaxError(1, len(line), CodeInvalidCommand, "%s is not a valid C command", line)
+		}
+		j := EnumValFromString(JumpStrings, jmpStr)
+		if j == -1 || JumpMnemonic(j) == JmpNull {
+			return Command{}, newSyntaxError(compCol+semi+1, len(jmpStr), CodeInvalidJump, "%s is not a valid jump expression", jmpStr)
+		}
+		jmp = JumpMnemonic(j)
+	}
+
+	comp := EnumValFromString(CompStrings, compStr)
+	if comp == -1 {
+		return Command{}, newSyntaxError(compCol, len(compStr), CodeInvalidComp, "%s is not a valid comp value", compStr)
+	}
+	return Command{C, CompMnemonic(comp), jmp, mloc, ""}, nil
+}
+
+// Symbol retrieves the symbol (variable name or constant) associated with the current command
+// if it exists
+func (p *Parser) Symbol() (string, error) {
+	ctype := p.CommandType()
+	if !ctype.IsPrintable() && ctype != L {
+		return "", errors.New("only A, C, and L commands can contain symbols")
+	}
+	cmd := p.CurrentCommand()
+	return cmd.symbol, nil
+}
+
+// Dest retrieves the memory location where the current C command should write its output
+func (p *Parser) Dest() (MemoryLocation, error) {
+	cmd := p.CurrentCommand()
+	if cmd.ctype != C {
+		return LocNull, errors.New("the parser has no C command loaded")
+	}
+	return cmd.mloc, nil
+}
+
+// Comp retrieves the comp mnemonic of the current C command
+func (p *Parser) Comp() (CompMnemonic, error) {
+	cmd := p.CurrentCommand()
+	if cmd.ctype != C {
+		return Comp0, errors.New("the parser has no C command loaded")
+	}
+	return cmd.comp, nil
+}
+
+// Jump retrieves the jump mnemonic of the current C command
+func (p *Parser) Jump() (JumpMnemonic, error) {
+	cmd := p.CurrentCommand()
+	if cmd.ctype != C {
+		return JmpNull, errors.New("the parser has no C command loaded")
+	}
+	return cmd.jump, nil
+}
+
+// Helper functions
+
+func filterEmpty(s []string) []string {
+	var r []string
+	for _, str := range s {
+		if str != "" {
+			r = append(r, str)
+		}
+	}
+	return r
+}
+
+func stripInlineComments(line string) string {
+	code, _ := splitComment(line)
+	return strings.Trim(code, " \t")
+}
@@ -1,4 +1,4 @@
-package main
+package asm
 
 import (
 	"os"
@@ -9,13 +9,13 @@ import (
 
 func emptySymbolTable() *SymbolTable {
 	table := make(map[string]int)
-	return &SymbolTable{table, 0}
+	return &SymbolTable{table, make(map[string]SymbolKind), 0}
 }
 func TestParser(t *testing.T) {
 	g := Goblin(t)
 	g.Describe("Basic statement parsing", func() {
-		f, _ := os.Open("./test/Test.asm")
-		p := NewParser(f, emptySymbolTable())
+		f, _ := os.Open("../test/Test.asm")
+		p := NewParser(f, f.Name(), emptySymbolTable())
 
 		g.It("Should recognize an A Statement", func() {
 			cmd, _ := p.parseLine("@M", true)
@@ -39,8 +39,8 @@ func TestParser(t *testing.T) {
 		})
 
 		g.Describe("C Statement parsing", func() {
-			f, _ := os.Open("./test/Test.asm")
-			p := NewParser(f, emptySymbolTable())
+			f, _ := os.Open("../test/Test.asm")
+			p := NewParser(f, f.Name(), emptySymbolTable())
 			g.It("Should parse an assignment C Statement", func() {
 				cmd, _ := p.parseCInstruction("D=D+A")
 				g.Assert(cmd.comp).Equal(CompDplusA)
@@ -71,10 +71,50 @@ func TestParser(t *testing.T) {
 				g.Assert(err != nil).IsTrue()
 			})
 		})
+		g.Describe("Combined dest and jump C Statement parsing", func() {
+			f, _ := os.Open("../test/Test.asm")
+			p := NewParser(f, f.Name(), emptySymbolTable())
+			for d, dest := range MemoryLocationStrings {
+				for j, jmp := range JumpStrings {
+					line := "D-1"
+					if MemoryLocation(d) != LocNull {
+						line = dest + "=" + line
+					}
+					if JumpMnemonic(j) != JmpNull {
+						line = line + ";" + jmp
+					}
+					if line == "D-1" {
+						continue
+					}
+					mloc, jump := MemoryLocation(d), JumpMnemonic(j)
+					g.It("Should parse "+line, func() {
+						cmd, err := p.parseCInstruction(line)
+						g.Assert(err == nil).IsTrue()
+						g.Assert(cmd.ctype).Equal(C)
+						g.Assert(cmd.comp).Equal(CompDminus1)
+						g.Assert(cmd.mloc).Equal(mloc)
+						g.Assert(cmd.jump).Equal(jump)
+					})
+				}
+			}
+			g.It("Should return an error for a malformed combined C Statement", func() {
+				_, err := p.parseCInstruction("D=D-1;")
+				g.Assert(err != nil).IsTrue()
+
+				_, err = p.parseCInstruction("D=;JNE")
+				g.Assert(err != nil).IsTrue()
+
+				_, err = p.parseCInstruction("P=D-1;JNE")
+				g.Assert(err != nil).IsTrue()
+
+				_, err = p.parseCInstruction("D=D-1;JJJ")
+				g.Assert(err != nil).IsTrue()
+			})
+		})
 		g.Describe("C Statement decomposition", func() {
 			g.It("Should correctly decompose an assignment C Statement", func() {
-				f, _ := os.Open("./test/CInstructions.asm")
-				p := NewParser(f, emptySymbolTable())
+				f, _ := os.Open("../test/CInstructions.asm")
+				p := NewParser(f, f.Name(), emptySymbolTable())
 				p.Advance(true)
 				dest, _ := p.Dest()
 				g.Assert(dest).Equal(LocD)
@@ -86,8 +126,8 @@ func TestParser(t *testing.T) {
 				g.Assert(sym).Equal("")
 			})
 			g.It("Should correctly decompose a comparison C Statement", func() {
-				f, _ := os.Open("./test/CInstructions.asm")
-				p := NewParser(f, emptySymbolTable())
+				f, _ := os.Open("../test/CInstructions.asm")
+				p := NewParser(f, f.Name(), emptySymbolTable())
 				p.Advance(true)
 				p.Advance(true)
 				dest, _ := p.Dest()
@@ -101,8 +141,8 @@ func TestParser(t *testing.T) {
 			})
 		})
 		g.Describe("A Statement parsing", func() {
-			f, _ := os.Open("./test/Test.asm")
-			p := NewParser(f, emptySymbolTable())
+			f, _ := os.Open("../test/Test.asm")
+			p := NewParser(f, f.Name(), emptySymbolTable())
 			g.It("Should parse an A Statement", func() {
 				cmd, _ := p.parseLine("@12345", true)
 				g.Assert(cmd.comp).Equal(Comp0)
@@ -114,9 +154,9 @@ func TestParser(t *testing.T) {
 	})
 
 	g.Describe("Label statement parsing", func() {
-		f, _ := os.Open("./test/Test.asm")
+		f, _ := os.Open("../test/Test.asm")
 		st := InitializeSymbolTable()
-		p := NewParser(f, &st)
+		p := NewParser(f, f.Name(), &st)
 
 		g.It("Should parse an L Statement", func() {
 			cmd, _ := p.parseLine("(LOOP)", true)
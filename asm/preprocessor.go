@@ -0,0 +1,376 @@
+package asm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Codes for problems found while expanding macros
+const (
+	CodeInvalidMacro      = "invalid-macro"
+	CodeMacroArguments    = "macro-arguments"
+	CodeUndefinedParam    = "undefined-parameter"
+	CodeMacroDepth        = "macro-depth"
+	CodeUnterminatedMacro = "unterminated-macro"
+)
+
+// maxMacroDepth limits how deeply macro invocations may nest, so that a
+// macro that invokes itself is reported rather than expanded forever
+const maxMacroDepth = 32
+
+// SourceLine is a single line of source text together with the position
+// it was read from
+type SourceLine struct {
+	Text string
+	File string
+	Line int
+	// Module is the top-level file of a multi-file program that the line
+	// belongs to, or empty if the program has a single file
+	Module string
+	// Expansion is the macro invocation that produced the line, or nil
+	// if the line was read directly from the source
+	Expansion *Expansion
+}
+
+// Expansion records the invocation of a macro
+type Expansion struct {
+	Macro string
+	Site  SourceLine
+}
+
+// macro is a named sequence of lines defined between .macro and .endm
+type macro struct {
+	name   string
+	params []string
+	body   []SourceLine
+}
+
+// preprocessor expands macro invocations before the source is parsed
+type preprocessor struct {
+	macros   map[string]*macro
+	diags    *Diagnostics
+	count    int
+	overflow bool
+}
+
+// StdinPath is the path that stands for standard input
+const StdinPath = "-"
+
+// sourceName returns the name of a source file in diagnostics
+func sourceName(path string) string {
+	if path == StdinPath {
+		return "<stdin>"
+	}
+	return path
+}
+
+// readFile reads the lines of a source file, or of standard input if the
+// path is StdinPath
+func readFile(path string) ([]SourceLine, error) {
+	if path == StdinPath {
+		return readLines(os.Stdin, sourceName(path))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLines(f, path)
+}
+
+// readLines reads the source and splits it into lines with splitLines
+func readLines(r io.Reader, file string) ([]SourceLine, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(src, file)
+}
+
+// splitLines splits the source into lines, numbered from 1. Lines may end in
+// LF or CRLF, and a byte order mark at the start of the source is dropped.
+func splitLines(src []byte, file string) ([]SourceLine, error) {
+	lines := make([]SourceLine, 0, bytes.Count(src, []byte{'\n'})+1)
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for l := 1; scanner.Scan(); l++ {
+		text := scanner.Text()
+		if l == 1 {
+			text = strings.TrimPrefix(text, byteOrderMark)
+		}
+		lines = append(lines, SourceLine{text, file, l, "", nil})
+	}
+	return lines, scanner.Err()
+}
+
+// preprocess removes macro definitions from the source and replaces each
+// invocation with the macro's body. Definitions have the form
+//
+//	.macro NAME param1, param2
+//	    ...
+//	.endm
+//
+// and are invoked as NAME arg1, arg2 once defined. Within the body %param is
+// replaced by the corresponding argument, and %%label by a name that is
+// unique to each expansion, so that a macro can define its own labels.
+// A % that is not followed by a parameter name is the modulo operator.
+// Other lines are passed through unchanged.
+func preprocess(lines []SourceLine, diags *Diagnostics) []SourceLine {
+	pp := preprocessor{map[string]*macro{}, diags, 0, false}
+	out := make([]SourceLine, 0, len(lines))
+	var def *macro
+	var defLine SourceLine
+	for _, l := range lines {
+		fields := strings.Fields(stripInlineComments(l.Text))
+		directive := ""
+		if len(fields) > 0 {
+			directive = fields[0]
+		}
+		switch {
+		case directive == MacroToken:
+			if def != nil {
+				pp.report(l, directiveColumn(l.Text, MacroToken), len(MacroToken), CodeInvalidMacro,
+					"macro definitions cannot be nested")
+				continue
+			}
+			def, defLine = pp.parseDefinition(l), l
+		case directive == EndMacroToken:
+			if def == nil {
+				pp.report(l, directiveColumn(l.Text, EndMacroToken), len(EndMacroToken), CodeInvalidMacro,
+					"%s without a matching %s", EndMacroToken, MacroToken)
+				continue
+			}
+			if def.name != "" {
+				pp.macros[def.name] = def
+			}
+			def = nil
+		case def != nil:
+			def.body = append(def.body, l)
+		default:
+			pp.overflow = false
+			out = pp.expand(out, l, 0)
+		}
+	}
+	if def != nil {
+		pp.report(defLine, directiveColumn(defLine.Text, MacroToken), len(MacroToken), CodeUnterminatedMacro,
+			"%s has no matching %s", MacroToken, EndMacroToken)
+	}
+	return out
+}
+
+// parseDefinition reads the name and parameters of a .macro line. If they
+// are invalid the problem is reported, and the returned macro has no name
+// so that its body is skipped without being registered.
+func (pp *preprocessor) parseDefinition(l SourceLine) *macro {
+	m := &macro{}
+	text := stripInlineComments(l.Text)
+	rest := strings.TrimSpace(strings.TrimPrefix(text, MacroToken))
+	name, args := splitInvocation(rest)
+	after := strings.Index(l.Text, MacroToken) + len(MacroToken)
+	col := after + strings.Index(l.Text[after:], name) + 1
+	switch {
+	case name == "":
+		pp.report(l, directiveColumn(l.Text, MacroToken), len(MacroToken), CodeInvalidMacro, "%s requires a name", MacroToken)
+		return m
+	case !isIdentifier(name):
+		pp.report(l, col, len(name), CodeInvalidMacro, "%s is not a valid macro name", name)
+		return m
+	case EnumValFromString(CompStrings, name) != -1:
+		pp.report(l, col, len(name), CodeInvalidMacro, "%s is a comp mnemonic and cannot name a macro", name)
+		return m
+	case pp.macros[name] != nil:
+		pp.report(l, col, len(name), CodeInvalidMacro, "macro %s is already defined", name)
+		return m
+	}
+	for i, param := range args {
+		if !isIdentifier(param) {
+			pp.report(l, strings.LastIndex(l.Text, param)+1, len(param), CodeInvalidMacro, "%s is not a valid parameter name", param)
+			return m
+		}
+		for _, prev := range args[:i] {
+			if prev == param {
+				pp.report(l, strings.LastIndex(l.Text, param)+1, len(param), CodeInvalidMacro, "parameter %s is declared twice", param)
+				return m
+			}
+		}
+	}
+	m.name, m.params = name, args
+	return m
+}
+
+// expand appends the line itself to out if it is not a macro invocation, and
+// otherwise the lines of the macro body with the arguments substituted
+// and any nested invocations expanded in turn
+func (pp *preprocessor) expand(out []SourceLine, l SourceLine, depth int) []SourceLine {
+	if pp.overflow {
+		return out
+	}
+	name, args := splitInvocation(stripInlineComments(l.Text))
+	m, ok := pp.macros[name]
+	if !ok {
+		return append(out, l)
+	}
+	col := strings.Index(l.Text, name) + 1
+	if depth >= maxMacroDepth {
+		pp.report(l, col, len(name), CodeMacroDepth, "macro invocations are nested more than %d deep", maxMacroDepth)
+		pp.overflow = true
+		return out
+	}
+	if len(args) != len(m.params) {
+		pp.report(l, col, len(name), CodeMacroArguments, "macro %s takes %d argument(s) but %d were given",
+			name, len(m.params), len(args))
+		return out
+	}
+	pp.count++
+	id := pp.count
+	site := &Expansion{name, l}
+	for _, b := range m.body {
+		line := SourceLine{b.Text, b.File, b.Line, l.Module, site}
+		text, err := substitute(b.Text, m, args, id)
+		if err != nil {
+			pp.reportSyntax(line, err)
+			continue
+		}
+		line.Text = text
+		out = pp.expand(out, line, depth+1)
+	}
+	return out
+}
+
+// substitute replaces the parameter references and local labels in a line of
+// a macro body; id distinguishes the local labels of each expansion
+func substitute(text string, m *macro, args []string, id int) (string, error) {
+	var out strings.Builder
+	comment := commentIndex(text)
+	for i := 0; i < len(text); {
+		if i == comment {
+			out.WriteString(text[i:])
+			break
+		}
+		if text[i] != ParamToken[0] {
+			out.WriteByte(text[i])
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], LocalToken) {
+			ident := leadingIdentifier(text[i+len(LocalToken):])
+			if ident == "" {
+				return "", newSyntaxError(i+1, len(LocalToken), CodeInvalidMacro, "%s must be followed by a label name", LocalToken)
+			}
+			fmt.Fprintf(&out, "%s$%s$%d", m.name, ident, id)
+			i += len(LocalToken) + len(ident)
+			continue
+		}
+		ident := leadingIdentifier(text[i+1:])
+		idx := EnumValFromString(m.params, ident)
+		if idx != -1 {
+			out.WriteString(args[idx])
+			i += len(ident) + 1
+			continue
+		}
+		// Anything else is the modulo operator, unless nothing precedes
+		// it that it could apply to
+		if isOperandStart(text[:i]) {
+			if ident == "" {
+				return "", newSyntaxError(i+1, 1, CodeUndefinedParam, "%s must be followed by a parameter name", ParamToken)
+			}
+			return "", newSyntaxError(i+1, len(ident)+1, CodeUndefinedParam, "macro %s has no parameter %s", m.name, ident)
+		}
+		out.WriteByte(text[i])
+		i++
+	}
+	return out.String(), nil
+}
+
+// isOperandStart returns whether the text before a % leaves it in the place
+// of an operand, where it cannot be the modulo operator
+func isOperandStart(before string) bool {
+	before = strings.TrimRight(before, " \t")
+	return before == "" || strings.IndexByte("@(=;,!+-*/%&|<>", before[len(before)-1]) != -1
+}
+
+func (pp *preprocessor) report(l SourceLine, column int, length int, code string, format string, args ...interface{}) {
+	pp.diags.Add(newLineDiagnostic(l, SeverityError, column, length, code, fmt.Sprintf(format, args...)))
+}
+
+func (pp *preprocessor) reportSyntax(l SourceLine, err error) {
+	se := err.(*syntaxError)
+	pp.diags.Add(newLineDiagnostic(l, SeverityError, se.column, se.length, se.code, se.msg))
+}
+
+// newLineDiagnostic creates a diagnostic pointing at the given column of a
+// line, with a note for each macro invocation the line was expanded from
+func newLineDiagnostic(l SourceLine, sev Severity, column int, length int, code string, msg string) Diagnostic {
+	d := Diagnostic{l.File, l.Line, column, length, sev, code, msg, l.Text, nil}
+	// A recursive macro expands the same invocation many times over, which
+	// is noted once with a count
+	repeats := 0
+	for e := l.Expansion; e != nil; e = e.Site.Expansion {
+		if next := e.Site.Expansion; next != nil && next.Macro == e.Macro && sameLine(next.Site, e.Site) {
+			repeats++
+			continue
+		}
+		col := strings.Index(e.Site.Text, e.Macro) + 1
+		note := fmt.Sprintf("in expansion of macro %s", e.Macro)
+		if repeats > 0 {
+			note += fmt.Sprintf(" (%d more times)", repeats)
+		}
+		d.Notes = append(d.Notes, Diagnostic{e.Site.File, e.Site.Line, col, len(e.Macro), SeverityNote, "", note, e.Site.Text, nil})
+		repeats = 0
+	}
+	return d
+}
+
+// sameLine returns whether two lines are the same line of the same file
+func sameLine(a SourceLine, b SourceLine) bool {
+	return a.File == b.File && a.Line == b.Line
+}
+
+// splitInvocation splits a line into its first word and the comma-separated
+// list that follows it
+func splitInvocation(text string) (string, []string) {
+	text = strings.TrimSpace(text)
+	i := strings.IndexAny(text, " \t")
+	if i == -1 {
+		return text, nil
+	}
+	name, rest := text[:i], strings.TrimSpace(text[i:])
+	if rest == "" {
+		return name, nil
+	}
+	args := strings.Split(rest, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return name, args
+}
+
+func directiveColumn(text string, directive string) int {
+	return strings.Index(text, directive) + 1
+}
+
+func leadingIdentifier(s string) string {
+	n := 0
+	for n < len(s) && isIdentChar(s[n], n == 0) {
+		n++
+	}
+	return s[:n]
+}
+
+func isIdentifier(s string) bool {
+	return s != "" && leadingIdentifier(s) == s
+}
+
+func isIdentChar(c byte, first bool) bool {
+	switch {
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
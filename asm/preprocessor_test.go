@@ -0,0 +1,145 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+// assembleSource assembles the given lines and returns the .hack output
+func assembleSource(lines ...string) (string, *Result, error) {
+	var out bytes.Buffer
+	res, err := Assemble(strings.NewReader(strings.Join(lines, "\n")), &out, Options{Filename: "Prog.asm"})
+	return out.String(), res, err
+}
+
+func TestPreprocessor(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Macro expansion", func() {
+		g.It("Should substitute arguments into the body", func() {
+			expanded, _, err := assembleSource(
+				".macro LOAD addr, reg",
+				"    @%addr",
+				"    %reg=M",
+				".endm",
+				"    LOAD 5, D",
+			)
+			expected, _, _ := assembleSource("@5", "D=M")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(expanded).Equal(expected)
+		})
+		g.It("Should leave % that is not followed by a parameter as the modulo operator", func() {
+			expanded, _, err := assembleSource(
+				".equ N 7",
+				".macro MOD x",
+				"    @N%2",
+				"    @N % 4",
+				"    @N % %x",
+				"    @%x%3",
+				".endm",
+				"    MOD 5",
+			)
+			expected, _, _ := assembleSource("@1", "@3", "@2", "@2")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(expanded).Equal(expected)
+		})
+		g.It("Should give each expansion its own local labels", func() {
+			src, _ := readLines(strings.NewReader(".macro SPIN\n(%%loop)\n@%%loop\n0;JMP\n.endm\nSPIN\nSPIN"), "Prog.asm")
+			var lines []string
+			for _, l := range preprocess(src, &Diagnostics{}) {
+				lines = append(lines, l.Text)
+			}
+			g.Assert(lines).Equal([]string{
+				"(SPIN$loop$1)", "@SPIN$loop$1", "0;JMP",
+				"(SPIN$loop$2)", "@SPIN$loop$2", "0;JMP",
+			})
+		})
+		g.It("Should expand nested invocations", func() {
+			expanded, _, err := assembleSource(
+				".macro INC reg",
+				"    %reg=%reg+1",
+				".endm",
+				".macro INC2 reg",
+				"    INC %reg",
+				"    INC %reg",
+				".endm",
+				"INC2 D",
+			)
+			expected, _, _ := assembleSource("D=D+1", "D=D+1")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(expanded).Equal(expected)
+		})
+		g.It("Should stop recursive macros at the depth limit", func() {
+			_, res, err := assembleSource(
+				".macro FOREVER",
+				"    FOREVER",
+				"    FOREVER",
+				".endm",
+				"FOREVER",
+			)
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Code).Equal(CodeMacroDepth)
+			notes := items[0].Notes
+			g.Assert(len(notes)).Equal(2)
+			g.Assert(notes[0].Line).Equal(2)
+			g.Assert(notes[0].Message).Equal(fmt.Sprintf("in expansion of macro FOREVER (%d more times)", maxMacroDepth-2))
+			g.Assert(notes[1].Line).Equal(5)
+			g.Assert(notes[1].Message).Equal("in expansion of macro FOREVER")
+		})
+	})
+
+	g.Describe("Macro errors", func() {
+		g.It("Should point at the body line and the invocation site", func() {
+			_, res, _ := assembleSource(
+				"// Macro errors",
+				".macro STORE reg",
+				"    M=%reg+2",
+				".endm",
+				"    @0",
+				"    STORE D",
+			)
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(1)
+			d := items[0]
+			g.Assert(d.Line).Equal(3)
+			g.Assert(d.Column).Equal(7)
+			g.Assert(d.Code).Equal(CodeInvalidComp)
+			g.Assert(d.Source).Equal("    M=D+2")
+			g.Assert(len(d.Notes)).Equal(1)
+			g.Assert(d.Notes[0].Error()).Equal("Prog.asm:6:5: note: in expansion of macro STORE")
+
+			var buf bytes.Buffer
+			res.Diagnostics.Print(&buf)
+			g.Assert(buf.String()).Equal(fmt.Sprintf("%s\n    M=D+2\n      ^~~\n%s\n    STORE D\n    ^~~~~\n",
+				d.Error(), d.Notes[0].Error()))
+		})
+		g.It("Should report malformed definitions and invocations", func() {
+			cases := []struct {
+				lines []string
+				line  int
+				code  string
+			}{
+				{[]string{".macro M", ".endm"}, 1, CodeInvalidMacro},
+				{[]string{".macro A1 x, x", ".endm"}, 1, CodeInvalidMacro},
+				{[]string{".macro A1", ".endm", ".macro A1", ".endm"}, 3, CodeInvalidMacro},
+				{[]string{".endm"}, 1, CodeInvalidMacro},
+				{[]string{"", ".macro A1"}, 2, CodeUnterminatedMacro},
+				{[]string{".macro A1 x", ".endm", "A1 1, 2"}, 3, CodeMacroArguments},
+				{[]string{".macro A1 x", "@%y", ".endm", "A1 1"}, 2, CodeUndefinedParam},
+			}
+			for _, c := range cases {
+				_, res, err := assembleSource(c.lines...)
+				g.Assert(err == nil).IsFalse()
+				items := res.Diagnostics.Items()
+				g.Assert(len(items)).Equal(1)
+				g.Assert(items[0].Line).Equal(c.line)
+				g.Assert(items[0].Code).Equal(c.code)
+			}
+		})
+	})
+}
@@ -0,0 +1,233 @@
+package asm
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codes for problems found while resolving scoped labels
+const (
+	CodeLocalLabel   = "local-label"
+	CodeNumericLabel = "numeric-label"
+	CodePrivateLabel = "private-label"
+)
+
+// LocalLabelToken starts a label that is scoped to the preceding global label
+const LocalLabelToken = "."
+
+// module holds the symbols that a file assembled together with others
+// exports and imports. Every other label and variable of the file is private
+// to it, and is stored in the symbol table with the module's prefix.
+type module struct {
+	prefix  string
+	file    string
+	exports map[string]bool
+	externs map[string]bool
+}
+
+// findModules returns the modules of a multi-file program, keyed by file.
+// Only files that use .global or .extern are modules; the symbols of the
+// other files are shared by the whole program.
+func findModules(lines []SourceLine) map[string]*module {
+	modules := map[string]*module{}
+	for _, l := range lines {
+		fields := strings.Fields(stripInlineComments(l.Text))
+		if l.Module == "" || len(fields) != 2 || (fields[0] != GlobalToken && fields[0] != ExternToken) {
+			continue
+		}
+		m, ok := modules[l.Module]
+		if !ok {
+			prefix := strings.TrimSuffix(filepath.Base(l.Module), filepath.Ext(l.Module))
+			m = &module{prefix, l.Module, map[string]bool{}, map[string]bool{}}
+			modules[l.Module] = m
+		}
+		if fields[0] == GlobalToken {
+			m.exports[fields[1]] = true
+		} else {
+			m.externs[fields[1]] = true
+		}
+	}
+	return modules
+}
+
+// scope tracks the state needed to turn the names written in the source into
+// the names stored in the symbol table, as the parser moves through the lines:
+//
+//	.loop  is a local label, stored as GLOBAL.loop after the preceding global label
+//	1:     defines a numeric label, which may be redefined any number of times;
+//	       @1b refers to the closest definition before, @1f to the closest after
+//
+// and, when several files are assembled together, the private names of a
+// module are stored as Prefix$name. constants holds the constants defined so
+// far, which are the only symbols other constants may be defined in terms of.
+type scope struct {
+	modules   map[string]*module
+	global    string
+	numeric   map[string]int
+	constants map[string]bool
+}
+
+func newScope(modules map[string]*module) *scope {
+	return &scope{modules, "", map[string]int{}, map[string]bool{}}
+}
+
+// define returns the qualified name of a label defined on the given line.
+// Labels that do not start a scope are those that are local or numeric, and
+// those produced by macro expansions, so that invoking a macro does not
+// change which global label the surrounding local labels belong to.
+func (s *scope) define(name string, l SourceLine) (string, error) {
+	if n, ok := numericLabel(name); ok {
+		s.numeric[n]++
+		return numericName(n, s.numeric[n]), nil
+	}
+	if strings.HasPrefix(name, LocalLabelToken) {
+		if s.global == "" {
+			return "", newSyntaxError(1, len(name), CodeLocalLabel, "local label %s is defined before any global label", name)
+		}
+		return s.global + name, nil
+	}
+	qualified := s.private(name, l.Module)
+	if l.Expansion == nil {
+		s.global = qualified
+	}
+	return qualified, nil
+}
+
+// symbolRef is a name referenced on a line, as written and as qualified by
+// the scope of that line. scope is the global label that local labels on
+// the line belong to.
+type symbolRef struct {
+	name      string
+	qualified string
+	scope     string
+}
+
+// reference qualifies a name referenced on the given line. Names that
+// cannot be resolved are only reported by check, once every label is known.
+func (s *scope) reference(name string, l SourceLine) symbolRef {
+	if n, dir, ok := numericReference(name); ok {
+		count := s.numeric[n]
+		if dir == 'f' {
+			count++
+		}
+		return symbolRef{name, numericName(n, count), s.global}
+	}
+	if strings.HasPrefix(name, LocalLabelToken) {
+		return symbolRef{name, s.global + name, s.global}
+	}
+	return symbolRef{name, s.private(name, l.Module), s.global}
+}
+
+// check reports a reference to a numeric or local label that is not
+// defined, or to a label that is private to another module
+func (s *scope) check(ref symbolRef, l SourceLine, st *SymbolTable) error {
+	name := ref.name
+	if n, dir, ok := numericReference(name); ok {
+		// The definitions of a numeric label are counted from 1, so a
+		// backward reference before the first one is never defined
+		if !st.Contains(ref.qualified) {
+			where := "before"
+			if dir == 'f' {
+				where = "after"
+			}
+			return newSyntaxError(1, len(name), CodeNumericLabel, "numeric label %s is not defined %s this line", n, where)
+		}
+		return nil
+	}
+	if strings.HasPrefix(name, LocalLabelToken) {
+		if st.Kind(ref.qualified) == SymLabel {
+			return nil
+		}
+		if ref.scope == "" {
+			return newSyntaxError(1, len(name), CodeLocalLabel, "local label %s is used before any global label", name)
+		}
+		msg := "local label %s is not defined in the scope of %s"
+		if others := s.scopesDefining(name, st); len(others) > 0 {
+			msg += "; it is defined in the scope of " + strings.Join(others, ", ")
+		}
+		return newSyntaxError(1, len(name), CodeLocalLabel, msg, name, ref.scope)
+	}
+	if ref.qualified != name && !st.Contains(ref.qualified) {
+		for _, m := range s.sortedModules() {
+			if m.file != l.Module && st.Kind(m.prefix+"$"+name) == SymLabel {
+				return newSyntaxError(1, len(name), CodePrivateLabel,
+					"%s is private to %s; export it with %s and declare it with %s", name, m.file, GlobalToken, ExternToken)
+			}
+		}
+	}
+	return nil
+}
+
+// private returns the name under which a symbol of a module is stored. Names
+// that the module exports or imports, and predefined symbols, are unchanged.
+func (s *scope) private(name string, file string) string {
+	m, ok := s.modules[file]
+	if !ok || m.exports[name] || m.externs[name] || builtinSymbols.Kind(name) == SymPredefined {
+		return name
+	}
+	return m.prefix + "$" + name
+}
+
+// isExported returns whether any module exports a symbol
+func (s *scope) isExported(name string) bool {
+	for _, m := range s.modules {
+		if m.exports[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// scopesDefining returns the global labels in whose scope a local label is defined
+func (s *scope) scopesDefining(name string, st *SymbolTable) []string {
+	var scopes []string
+	for _, sym := range st.Symbols(SymLabel) {
+		if strings.HasSuffix(sym.Name, name) && len(sym.Name) > len(name) {
+			scopes = append(scopes, strings.TrimSuffix(sym.Name, name))
+		}
+	}
+	return scopes
+}
+
+func (s *scope) sortedModules() []*module {
+	var modules []*module
+	for _, m := range s.modules {
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].file < modules[j].file })
+	return modules
+}
+
+// numericLabel returns the number of a numeric label definition such as 1:
+func numericLabel(name string) (string, bool) {
+	// Most names are symbols; skip the parse, and the error it allocates
+	if name == "" || name[0] < '0' || name[0] > '9' {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(name, 10, 16); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// numericReference splits a reference such as 1b or 2f into its number and direction
+func numericReference(name string) (string, byte, bool) {
+	if len(name) < 2 {
+		return "", 0, false
+	}
+	dir := name[len(name)-1]
+	n, ok := numericLabel(name[:len(name)-1])
+	return n, dir, ok && (dir == 'b' || dir == 'f')
+}
+
+// numericName is the name under which the count-th definition of a numeric
+// label is stored. Symbols cannot start with a digit, so it cannot clash
+// with any name written in the source.
+func numericName(n string, count int) string {
+	return n + "$" + strconv.Itoa(count)
+}
+
+// builtinSymbols holds the predefined symbols, which are never private to a module
+var builtinSymbols = InitializeSymbolTable()
@@ -0,0 +1,110 @@
+package asm
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestScope(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Local labels", func() {
+		g.It("Should scope local labels to the preceding global label", func() {
+			out, res, err := assembleSource(
+				"(FOO)",
+				"(.loop)",
+				"    @.loop",
+				"    0;JMP",
+				"(BAR)",
+				"    @.loop",
+				"(.loop)",
+				"    0;JMP",
+				"    @FOO.loop",
+			)
+			expected, _, _ := assembleSource("@0", "0;JMP", "@3", "0;JMP", "@0")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+			g.Assert(res.Symbols.Kind("BAR.loop")).Equal(SymLabel)
+		})
+		g.It("Should not let macro labels start a new scope", func() {
+			out, _, err := assembleSource(
+				".macro SKIP",
+				"(%%here)",
+				".endm",
+				"(FOO)",
+				"    SKIP",
+				"(.loop)",
+				"    @FOO.loop",
+			)
+			expected, _, _ := assembleSource("@0")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+		g.It("Should report local labels used outside their scope", func() {
+			_, res, _ := assembleSource(
+				"    @.start",
+				"(.early)",
+				"(FOO)",
+				"(.loop)",
+				"(BAR)",
+				"    @.loop",
+			)
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(3)
+			g.Assert(items[0].Code).Equal(CodeLocalLabel)
+			g.Assert(items[0].Column).Equal(6)
+			g.Assert(items[1].Line).Equal(2)
+			g.Assert(items[1].Column).Equal(2)
+			g.Assert(items[2].Message).Equal("local label .loop is not defined in the scope of BAR; it is defined in the scope of FOO")
+		})
+	})
+
+	g.Describe("Numeric labels", func() {
+		g.It("Should resolve backward and forward references", func() {
+			out, _, err := assembleSource(
+				"1:",
+				"    @1f",
+				"    0;JMP",
+				"    @1b",
+				"1:",
+				"    @1b",
+				"    0;JMP",
+			)
+			expected, _, _ := assembleSource("@3", "0;JMP", "@0", "@3", "0;JMP")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out).Equal(expected)
+		})
+		g.It("Should report references with no matching definition", func() {
+			_, res, _ := assembleSource("    @2b", "2:", "    @2f")
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Code).Equal(CodeNumericLabel)
+			g.Assert(items[0].Message).Equal("numeric label 2 is not defined before this line")
+			g.Assert(items[1].Message).Equal("numeric label 2 is not defined after this line")
+		})
+	})
+
+	g.Describe("Module scoping", func() {
+		g.It("Should keep the labels and variables of each file private", func() {
+			var out bytes.Buffer
+			res, err := AssembleFiles([]string{"../test/scope/A.asm", "../test/scope/B.asm"}, &out, Options{})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(res.Symbols.GetAddress("start")).Equal(0)
+			g.Assert(res.Symbols.GetAddress("A$LOOP")).Equal(2)
+			g.Assert(res.Symbols.GetAddress("B$LOOP")).Equal(6)
+			g.Assert(res.Symbols.GetAddress("A$count")).Equal(16)
+			g.Assert(res.Symbols.GetAddress("B$count")).Equal(17)
+		})
+		g.It("Should report private and unexported symbols", func() {
+			var out bytes.Buffer
+			res, err := AssembleFiles([]string{"../test/scope/A.asm", "../test/scope/Bad.asm"}, &out, Options{})
+			g.Assert(err == nil).IsFalse()
+			items := res.Diagnostics.Items()
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Code).Equal(CodeUndefinedSymbol)
+			g.Assert(items[1].Error()).Equal("../test/scope/Bad.asm:3:6: error: LOOP is private to ../test/scope/A.asm; " +
+				"export it with .global and declare it with .extern [private-label]")
+		})
+	})
+}
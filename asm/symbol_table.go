@@ -0,0 +1,152 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SymbolTable maps string symbols to memory addresses
+type SymbolTable struct {
+	table   map[string]int
+	kinds   map[string]SymbolKind
+	nextRAM int
+}
+
+// Symbol is a single entry of the symbol table
+type Symbol struct {
+	Name    string
+	Address int
+	Kind    SymbolKind
+}
+
+// AddElement inserts a new element (variable or instruction label) into
+// the table and allocates a memory location to it. If -1 is passed in as the
+// memory location, the next available RAM address will be allocated
+func (st *SymbolTable) AddElement(sym string, addr int) {
+	if addr == -1 {
+		st.Allocate(sym, 1)
+		return
+	}
+	st.table[sym] = addr
+	st.kinds[sym] = SymLabel
+}
+
+// Allocate inserts a variable that takes up size consecutive RAM locations,
+// and returns the address of the first
+func (st *SymbolTable) Allocate(sym string, size int) int {
+	addr := st.nextRAM
+	st.nextRAM += size
+	st.table[sym] = addr
+	st.kinds[sym] = SymVariable
+	return addr
+}
+
+// shiftLabels moves every label forward in ROM by the given number of words
+func (st *SymbolTable) shiftLabels(offset int) {
+	for sym, kind := range st.kinds {
+		if kind == SymLabel {
+			st.table[sym] += offset
+		}
+	}
+}
+
+// AddExtern inserts a symbol that is defined in another object file. Its
+// address is not known until link time, so it resolves to 0 until then.
+func (st *SymbolTable) AddExtern(sym string) {
+	st.table[sym] = 0
+	st.kinds[sym] = SymExtern
+}
+
+// AddConstant inserts or updates a named constant. Unlike a variable, it
+// does not take up a RAM address.
+func (st *SymbolTable) AddConstant(sym string, value int) {
+	st.table[sym] = value
+	st.kinds[sym] = SymConstant
+}
+
+// Kind returns whether a symbol is predefined, a label or a variable,
+// or SymNull if it does not exist in the table
+func (st *SymbolTable) Kind(sym string) SymbolKind {
+	return st.kinds[sym]
+}
+
+// Symbols returns every symbol of the given kind, ordered by address and then by name
+func (st *SymbolTable) Symbols(kind SymbolKind) []Symbol {
+	var syms []Symbol
+	for name, k := range st.kinds {
+		if k == kind {
+			syms = append(syms, Symbol{name, st.table[name], k})
+		}
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].Address != syms[j].Address {
+			return syms[i].Address < syms[j].Address
+		}
+		return syms[i].Name < syms[j].Name
+	})
+	return syms
+}
+
+// WriteSymbols writes the labels, variables and constants, one per line
+// in the form "kind name address", such as "label LOOP 10"
+func (st *SymbolTable) WriteSymbols(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, kind := range []SymbolKind{SymLabel, SymVariable, SymConstant} {
+		for _, sym := range st.Symbols(kind) {
+			fmt.Fprintf(bw, "%s %s %d\n", SymbolKindStrings[kind], sym.Name, sym.Address)
+		}
+	}
+	return bw.Flush()
+}
+
+// NextRAM returns the RAM address that the next variable will be allocated
+func (st *SymbolTable) NextRAM() int {
+	return st.nextRAM
+}
+
+// Contains returns whether or not a given symbol exists in the table
+func (st *SymbolTable) Contains(sym string) bool {
+	if _, ok := st.table[sym]; ok {
+		return true
+	}
+	return false
+}
+
+// Debug is used to inspect the symbol table
+func (st *SymbolTable) Debug() {
+	fmt.Printf("%v", st.table)
+}
+
+// GetAddress returns the memory address of a given symbol, or -1
+// if it does not exist in the table
+func (st *SymbolTable) GetAddress(sym string) int {
+	if addr, ok := st.table[sym]; ok {
+		return addr
+	}
+	return -1
+}
+
+// InitializeSymbolTable returns a new SymbolTable pre-populated with the built-in
+// symbols. Sets nextRAM to 16 (this is where the RAM addresses allocated for variables begin)
+func InitializeSymbolTable() SymbolTable {
+	pre := map[string]int{
+		"SP":     0,
+		"LCL":    1,
+		"ARG":    2,
+		"THIS":   3,
+		"THAT":   4,
+		"SCREEN": 16384,
+		"KBD":    24576,
+	}
+	for i := 0; i < 16; i++ {
+		k := fmt.Sprintf("R%d", i)
+		pre[k] = i
+	}
+	kinds := make(map[string]SymbolKind)
+	for k := range pre {
+		kinds[k] = SymPredefined
+	}
+	return SymbolTable{pre, kinds, 16}
+}
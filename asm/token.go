@@ -0,0 +1,22 @@
+package asm
+
+// Constants that signify special types of pseudo-command
+const (
+	CommentToken  = "//"
+	ACmdToken     = "@"
+	LabelToken    = "("
+	MacroToken    = ".macro"
+	EndMacroToken = ".endm"
+	ParamToken    = "%"
+	LocalToken    = "%%"
+	IncludeToken  = ".include"
+	OnceToken     = ".once"
+	GlobalToken   = ".global"
+	ExternToken   = ".extern"
+	EquToken      = ".equ"
+	DefineToken   = ".define"
+	SetToken      = ".set"
+	DataToken     = ".data"
+	WordToken     = ".word"
+	StringToken   = ".string"
+)
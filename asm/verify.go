@@ -0,0 +1,97 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Codes for differences found by Verify
+const (
+	CodeWordMismatch   = "word-mismatch"
+	CodeLengthMismatch = "length-mismatch"
+)
+
+// VerifyOptions configures a call to Verify. Format, Object and the writers
+// of Options are ignored, as the program is compared as .hack text.
+type VerifyOptions struct {
+	Options
+	// Reference labels diagnostics about the reference ROM image; it is not opened
+	Reference string
+}
+
+// Verify assembles Hack assembly from r and compares the ROM image, word by
+// word, with the reference .hack text read from ref. Each address at which
+// they differ is reported at the source line of its instruction, with the
+// expected and assembled words and their disassembly, followed by the first
+// address past the end of the shorter one if their lengths differ. If the
+// source has errors, the reference is not valid or the two differ, an
+// *AssemblyError is returned.
+func Verify(r io.Reader, ref io.Reader, opts VerifyOptions) (*Result, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	opts.Format, opts.Object = FormatHack, false
+	opts.Listing, opts.RAMImage, opts.DebugInfo = nil, nil, ioutil.Discard
+	asm := newAssembly(opts.Options)
+	var out bytes.Buffer
+	err = asm.convertSource(src, &out)
+	res := asm.result()
+	if err != nil {
+		return res, err
+	}
+
+	expected, refLines, err := readWords(ref, opts.Reference, &asm.diags)
+	if err != nil {
+		return res, err
+	}
+	if asm.diags.HasErrors() {
+		return res, &AssemblyError{opts.Reference, asm.diags.Items()}
+	}
+	actual, _, err := readWords(&out, opts.Filename, &asm.diags)
+	if err != nil {
+		return res, err
+	}
+	asm.compareWords(expected, actual, refLines, opts.Reference)
+	if asm.diags.HasErrors() {
+		return res, &AssemblyError{opts.Filename, asm.diags.Items()}
+	}
+	return res, nil
+}
+
+// compareWords reports the differences between the reference ROM image and
+// the assembled one, whose instructions were recorded in the debug info
+func (asm *assembly) compareWords(expected []uint16, actual []uint16, refLines []string, reference string) {
+	dec := newDecoder()
+	describe := func(word uint16) string {
+		return fmt.Sprintf("%016b (%s)", word, dec.formatWord(word))
+	}
+	lines := asm.debug.lines
+	for addr := 0; addr < len(expected) && addr < len(actual); addr++ {
+		if expected[addr] != actual[addr] {
+			asm.diags.Add(instructionDiagnostic(lines[addr], CodeWordMismatch, fmt.Sprintf("ROM[%d]: expected %s, assembled %s",
+				addr, describe(expected[addr]), describe(actual[addr]))))
+		}
+	}
+	switch n := len(actual); {
+	case n > len(expected):
+		addr := len(expected)
+		asm.diags.Add(instructionDiagnostic(lines[addr], CodeLengthMismatch, fmt.Sprintf("ROM[%d]: assembled %s past the end of the %d-word reference",
+			addr, describe(actual[addr]), len(expected))))
+	case n < len(expected):
+		text := refLines[n]
+		asm.diags.Add(Diagnostic{reference, n + 1, 1, len(text), SeverityError, CodeLengthMismatch,
+			fmt.Sprintf("ROM[%d]: expected %s past the end of the %d-word program", n, describe(expected[n]), n), text, nil})
+	}
+}
+
+// instructionDiagnostic creates an error that underlines the instruction on a line
+func instructionDiagnostic(l SourceLine, code string, msg string) Diagnostic {
+	text, _ := splitComment(l.Text)
+	trimmed := strings.TrimLeft(text, " \t")
+	column := len(text) - len(trimmed) + 1
+	return newLineDiagnostic(l, SeverityError, column, len(strings.TrimRight(trimmed, " \t")), code, msg)
+}
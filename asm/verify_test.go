@@ -0,0 +1,63 @@
+package asm
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestVerify(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Verification", func() {
+		verify := func(src string, ref ...string) (*Result, error) {
+			opts := VerifyOptions{Options{Filename: "Prog.asm"}, "ProgExpected.hack"}
+			return Verify(strings.NewReader(src), strings.NewReader(strings.Join(ref, "\n")), opts)
+		}
+		src := "(LOOP)\n   @LOOP\n   D;JGT  // wait\n"
+		g.It("Should accept a program that matches the reference", func() {
+			res, err := verify(src, "0000000000000000", "1110001100000001")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(res.Diagnostics.Items())).Equal(0)
+		})
+		g.It("Should report each differing word at its source line", func() {
+			_, err := verify(src, "0000000000000001", "1110001100000010")
+			g.Assert(err == nil).IsFalse()
+			items := err.(*AssemblyError).Diagnostics
+			g.Assert(len(items)).Equal(2)
+			g.Assert(items[0].Error()).Equal("Prog.asm:2:4: error: ROM[0]: expected 0000000000000001 (@1), " +
+				"assembled 0000000000000000 (@0) [word-mismatch]")
+			g.Assert(items[1].Line).Equal(3)
+			g.Assert(items[1].Column).Equal(4)
+			g.Assert(items[1].Length).Equal(5)
+			g.Assert(items[1].Message).Equal("ROM[1]: expected 1110001100000010 (D;JEQ), assembled 1110001100000001 (D;JGT)")
+		})
+		g.It("Should report programs and references of different lengths", func() {
+			_, err := verify(src, "0000000000000000")
+			items := err.(*AssemblyError).Diagnostics
+			g.Assert(len(items)).Equal(1)
+			g.Assert(items[0].Line).Equal(3)
+			g.Assert(items[0].Message).Equal("ROM[1]: assembled 1110001100000001 (D;JGT) past the end of the 1-word reference")
+			_, err = verify(src, "0000000000000000", "1110001100000001", "1111111111111111")
+			items = err.(*AssemblyError).Diagnostics
+			g.Assert(items[0].Error()).Equal("ProgExpected.hack:3:1: error: ROM[2]: expected 1111111111111111 " +
+				"(invalid instruction) past the end of the 2-word program [length-mismatch]")
+		})
+		g.It("Should reject a reference that is not .hack text", func() {
+			_, err := verify(src, "0000000000000000", "D;JGT")
+			g.Assert(err.Error()).Equal("1 error(s) found in ProgExpected.hack")
+		})
+		g.It("Should verify the example programs", func() {
+			for _, name := range []string{"Max", "Rect", "Pong", "PongL"} {
+				src, _ := os.Open("../test/" + name + ".asm")
+				ref, _ := os.Open("../test/" + name + "Expected.hack")
+				res, err := Verify(src, ref, VerifyOptions{Options{Filename: name + ".asm"}, name + "Expected.hack"})
+				src.Close()
+				ref.Close()
+				g.Assert(err == nil).IsTrue()
+				g.Assert(res.Instructions > 0).IsTrue()
+			}
+		})
+	})
+}
@@ -0,0 +1,255 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BarthesSimpson/assembler/asm"
+)
+
+// Sizes of the memories and the addresses of the memory-mapped devices.
+// SCREEN and KBD match the predefined symbols in asm.InitializeSymbolTable.
+const (
+	ROMSize      = 32768
+	RAMSize      = 32768
+	ScreenAddr   = 16384
+	KeyboardAddr = 24576
+)
+
+// Halt is an integer enum type
+type Halt int
+
+// Enum for the possible reasons that Run stopped:
+// HaltNone means the CPU can keep running
+// HaltCycles means the requested number of cycles was executed
+// HaltLoop means the program entered a tight infinite loop, which is
+// how Hack programs conventionally end
+// HaltEnd means the PC moved past the last instruction that was loaded
+const (
+	HaltNone Halt = iota
+	HaltCycles
+	HaltLoop
+	HaltEnd
+)
+
+// HaltStrings enables converting a Halt to and from its string representation
+var HaltStrings = []string{"running", "cycle limit reached", "infinite loop", "end of program"}
+
+func (h Halt) String() string {
+	return HaltStrings[h]
+}
+
+// CPU is the state of a Hack computer: its registers, the instruction
+// memory and the data memory (which includes the screen and keyboard)
+type CPU struct {
+	A      uint16
+	D      uint16
+	PC     uint16
+	ROM    [ROMSize]uint16
+	RAM    [RAMSize]uint16
+	Cycles int
+	size   int
+}
+
+// NewCPU is a factory that creates a CPU with zeroed registers and memories
+func NewCPU() *CPU {
+	return &CPU{}
+}
+
+// Reset sets the registers and the cycle count back to zero, leaving the memories untouched
+func (cpu *CPU) Reset() {
+	cpu.A, cpu.D, cpu.PC, cpu.Cycles = 0, 0, 0, 0
+}
+
+// Load copies a program into ROM, starting at address 0
+func (cpu *CPU) Load(program []uint16) error {
+	if len(program) > ROMSize {
+		return fmt.Errorf("program has %d words but the ROM only holds %d", len(program), ROMSize)
+	}
+	cpu.ROM = [ROMSize]uint16{}
+	copy(cpu.ROM[:], program)
+	cpu.size = len(program)
+	return nil
+}
+
+// LoadHack reads binary .hack text, one 16-digit word per line, into ROM
+func (cpu *CPU) LoadHack(r io.Reader) error {
+	program, err := readHack(r)
+	if err != nil {
+		return err
+	}
+	return cpu.Load(program)
+}
+
+// LoadRAM reads a RAM image in .hack text, such as the one written by the
+// assembler for data directives, into RAM starting at address 0
+func (cpu *CPU) LoadRAM(r io.Reader) error {
+	words, err := readHack(r)
+	if err != nil {
+		return err
+	}
+	if len(words) > RAMSize {
+		return fmt.Errorf("RAM image has %d words but the RAM only holds %d", len(words), RAMSize)
+	}
+	copy(cpu.RAM[:], words)
+	return nil
+}
+
+// readHack reads binary .hack text, one 16-digit word per line
+func readHack(r io.Reader) ([]uint16, error) {
+	var words []uint16
+	scanner := bufio.NewScanner(r)
+	for l := 1; scanner.Scan(); l++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		word, err := strconv.ParseUint(text, 2, 16)
+		if err != nil || len(text) != 16 {
+			return nil, fmt.Errorf("line %d: %s is not a 16-bit binary word", l, text)
+		}
+		words = append(words, uint16(word))
+	}
+	return words, scanner.Err()
+}
+
+// LoadAssembly assembles Hack assembly source and loads the result into ROM.
+// The file name is only used to label diagnostics.
+func (cpu *CPU) LoadAssembly(r io.Reader, file string) error {
+	var out bytes.Buffer
+	if _, err := asm.Assemble(r, &out, asm.Options{Filename: file}); err != nil {
+		return err
+	}
+	return cpu.LoadHack(&out)
+}
+
+// LoadFile loads a .hack file, or assembles and loads a .asm file
+func (cpu *CPU) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".asm") {
+		return cpu.LoadAssembly(f, path)
+	}
+	return cpu.LoadHack(f)
+}
+
+// Step executes the instruction at PC. It reports HaltLoop if that instruction
+// was a jump into a tight infinite loop, and HaltEnd if PC is past the program.
+func (cpu *CPU) Step() Halt {
+	if int(cpu.PC) >= cpu.size {
+		return HaltEnd
+	}
+	pc := cpu.PC
+	ins := cpu.ROM[pc]
+	cpu.Cycles++
+
+	// A instruction: load the value into A
+	if ins&0x8000 == 0 {
+		cpu.A = ins
+		cpu.PC++
+		return HaltNone
+	}
+
+	// C instruction: compute, store and maybe jump. The jump target and the
+	// memory address are the value of A before this instruction updates it.
+	y := cpu.A
+	if ins&0x1000 != 0 {
+		y = cpu.RAM[cpu.A%RAMSize]
+	}
+	out := alu(cpu.D, y, ins>>6)
+	addr := cpu.A
+	if ins&0x0008 != 0 {
+		cpu.RAM[addr%RAMSize] = out
+	}
+	if ins&0x0020 != 0 {
+		cpu.A = out
+	}
+	if ins&0x0010 != 0 {
+		cpu.D = out
+	}
+
+	neg, zero := int16(out) < 0, out == 0
+	jump := (ins&0x4 != 0 && neg) || (ins&0x2 != 0 && zero) || (ins&0x1 != 0 && !neg && !zero)
+	if !jump {
+		cpu.PC++
+		return HaltNone
+	}
+	cpu.PC = addr
+	if cpu.isTightLoop(pc) {
+		return HaltLoop
+	}
+	return HaltNone
+}
+
+// isTightLoop reports whether the jump just taken from the instruction at pc
+// can only ever lead back to itself: either a jump to its own address, or the
+// conventional "@LOOP / 0;JMP" pair jumping back to the A instruction. The
+// jump must also be unconditional, or store nothing, so that it is certain
+// to be taken again.
+func (cpu *CPU) isTightLoop(pc uint16) bool {
+	ins := cpu.ROM[pc]
+	if ins&0x7 != 0x7 && ins&0x38 != 0 {
+		return false
+	}
+	return cpu.PC == pc || (cpu.PC+1 == pc && cpu.ROM[cpu.PC] == cpu.PC)
+}
+
+// Run executes instructions until the CPU halts or maxCycles instructions
+// have been executed. A non-positive maxCycles means no limit.
+func (cpu *CPU) Run(maxCycles int) Halt {
+	for n := 0; maxCycles <= 0 || n < maxCycles; n++ {
+		if h := cpu.Step(); h != HaltNone {
+			return h
+		}
+	}
+	return HaltCycles
+}
+
+// Dump writes the signed values of RAM[from] through RAM[to] inclusive, one per line
+func (cpu *CPU) Dump(w io.Writer, from int, to int) error {
+	if from < 0 || to >= RAMSize || from > to {
+		return fmt.Errorf("%d-%d is not a valid RAM range", from, to)
+	}
+	for addr := from; addr <= to; addr++ {
+		if _, err := fmt.Fprintf(w, "RAM[%d] = %d\n", addr, int16(cpu.RAM[addr])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alu computes the Hack ALU function selected by the six control bits
+// zx nx zy ny f no, taken from the low bits of c
+func alu(x uint16, y uint16, c uint16) uint16 {
+	if c&0x20 != 0 {
+		x = 0
+	}
+	if c&0x10 != 0 {
+		x = ^x
+	}
+	if c&0x08 != 0 {
+		y = 0
+	}
+	if c&0x04 != 0 {
+		y = ^y
+	}
+	var out uint16
+	if c&0x02 != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+	if c&0x01 != 0 {
+		out = ^out
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package emulator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func load(g *G, src string) *CPU {
+	cpu := NewCPU()
+	err := cpu.LoadAssembly(strings.NewReader(src), "Test.asm")
+	g.Assert(err == nil).IsTrue()
+	return cpu
+}
+
+func TestCPU(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Instruction execution", func() {
+		g.It("Should load A instructions into A", func() {
+			cpu := load(g, "@12345")
+			cpu.Step()
+			g.Assert(cpu.A).Equal(uint16(12345))
+			g.Assert(cpu.PC).Equal(uint16(1))
+		})
+		g.It("Should compute every comp mnemonic", func() {
+			cases := []struct {
+				comp     string
+				expected int16
+			}{
+				{"0", 0}, {"1", 1}, {"-1", -1}, {"D", 7}, {"A", 3}, {"!D", ^7}, {"!A", ^3},
+				{"-D", -7}, {"-A", -3}, {"D+1", 8}, {"A+1", 4}, {"D-1", 6}, {"A-1", 2},
+				{"D+A", 10}, {"D-A", 4}, {"A-D", -4}, {"D&A", 3}, {"D|A", 7},
+				{"M", 5}, {"!M", ^5}, {"-M", -5}, {"M+1", 6}, {"M-1", 4},
+				{"D+M", 12}, {"D-M", 2}, {"M-D", -2}, {"D&M", 5}, {"D|M", 7},
+			}
+			for _, c := range cases {
+				cpu := load(g, "D="+c.comp)
+				cpu.A, cpu.D, cpu.RAM[3] = 3, 7, 5
+				cpu.Step()
+				g.Assert(int16(cpu.D)).Equal(c.expected)
+			}
+		})
+		g.It("Should store into every destination", func() {
+			cpu := load(g, "@100\nAMD=1")
+			cpu.Run(2)
+			g.Assert(cpu.A).Equal(uint16(1))
+			g.Assert(cpu.D).Equal(uint16(1))
+			g.Assert(cpu.RAM[100]).Equal(uint16(1))
+		})
+		g.It("Should only jump when the condition holds", func() {
+			cases := []struct {
+				jump  string
+				taken []bool // for negative, zero and positive results
+			}{
+				{"JGT", []bool{false, false, true}},
+				{"JEQ", []bool{false, true, false}},
+				{"JGE", []bool{false, true, true}},
+				{"JLT", []bool{true, false, false}},
+				{"JNE", []bool{true, false, true}},
+				{"JLE", []bool{true, true, false}},
+				{"JMP", []bool{true, true, true}},
+			}
+			for _, c := range cases {
+				for i, d := range []int16{-2, 0, 2} {
+					cpu := load(g, "@10\nD;"+c.jump)
+					cpu.D = uint16(d)
+					cpu.Run(2)
+					g.Assert(cpu.PC == 10).Equal(c.taken[i])
+				}
+			}
+		})
+	})
+
+	g.Describe("Running programs", func() {
+		g.It("Should add two numbers", func() {
+			cpu := NewCPU()
+			g.Assert(cpu.LoadFile("../test/AddExpected.hack") == nil).IsTrue()
+			g.Assert(cpu.Run(100)).Equal(HaltEnd)
+			g.Assert(cpu.RAM[0]).Equal(uint16(5))
+		})
+		g.It("Should find the max of two numbers", func() {
+			for _, c := range [][3]uint16{{3, 5, 5}, {23456, 12345, 23456}} {
+				cpu := NewCPU()
+				g.Assert(cpu.LoadFile("../test/Max.asm") == nil).IsTrue()
+				cpu.RAM[0], cpu.RAM[1] = c[0], c[1]
+				g.Assert(cpu.Run(100)).Equal(HaltLoop)
+				g.Assert(cpu.RAM[2]).Equal(c[2])
+			}
+		})
+		g.It("Should draw a rectangle on the screen", func() {
+			cpu := NewCPU()
+			g.Assert(cpu.LoadFile("../test/Rect.asm") == nil).IsTrue()
+			cpu.RAM[0] = 4
+			g.Assert(cpu.Run(1000)).Equal(HaltLoop)
+			for row := 0; row < 5; row++ {
+				expected := uint16(0xffff)
+				if row == 4 {
+					expected = 0
+				}
+				g.Assert(cpu.RAM[ScreenAddr+32*row]).Equal(expected)
+			}
+		})
+		g.It("Should stop at the cycle limit", func() {
+			cpu := NewCPU()
+			g.Assert(cpu.LoadFile("../test/Pong.asm") == nil).IsTrue()
+			g.Assert(cpu.Run(5000)).Equal(HaltCycles)
+			g.Assert(cpu.Cycles).Equal(5000)
+		})
+		g.It("Should not treat a conditional loop as infinite", func() {
+			cpu := load(g, "@5\nD=A\n(LOOP)\n@LOOP\nD=D-1;JGT\n(END)\n@END\n0;JMP")
+			g.Assert(cpu.Run(100)).Equal(HaltLoop)
+			g.Assert(cpu.D).Equal(uint16(0))
+			g.Assert(cpu.PC).Equal(uint16(4))
+		})
+	})
+
+	g.Describe("RAM dumps", func() {
+		g.It("Should print signed values", func() {
+			cpu := NewCPU()
+			cpu.RAM[1] = 0xffff
+			var out bytes.Buffer
+			g.Assert(cpu.Dump(&out, 0, 1) == nil).IsTrue()
+			g.Assert(out.String()).Equal("RAM[0] = 0\nRAM[1] = -1\n")
+		})
+		g.It("Should reject invalid ranges", func() {
+			var out bytes.Buffer
+			g.Assert(NewCPU().Dump(&out, 2, 1) != nil).IsTrue()
+			g.Assert(NewCPU().Dump(&out, 0, RAMSize) != nil).IsTrue()
+		})
+	})
+
+	g.Describe("RAM images", func() {
+		g.It("Should preload RAM from address 0", func() {
+			cpu := NewCPU()
+			err := cpu.LoadRAM(strings.NewReader("0000000000000000\n1111111111111111\n0000000000101010\n"))
+			g.Assert(err == nil).IsTrue()
+			g.Assert(cpu.RAM[1]).Equal(uint16(0xffff))
+			g.Assert(cpu.RAM[2]).Equal(uint16(42))
+		})
+		g.It("Should reject malformed words", func() {
+			g.Assert(NewCPU().LoadRAM(strings.NewReader("101\n")) != nil).IsTrue()
+		})
+	})
+}
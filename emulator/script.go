@@ -0,0 +1,575 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Script is a parsed CPUEmulator test script (.tst). It supports the commands
+// used by CPU tests: load, output-file, compare-to, output-list, set, repeat,
+// while, tick, tock, ticktock and output. echo, clear-echo and breakpoint
+// commands are accepted and ignored.
+type Script struct {
+	path  string
+	dir   string
+	stmts []statement
+
+	cpu     *CPU
+	columns []column
+	out     io.Writer
+	outFile *os.File
+	cmp     []string
+	cmpPath string
+	row     int
+}
+
+// statement is a single script command, or a repeat/while block of commands
+type statement struct {
+	line int
+	name string
+	args []string
+	body []statement
+}
+
+// column is an entry of the output-list: a variable and the format it is printed in
+type column struct {
+	name   string
+	format byte
+	left   int
+	width  int
+	right  int
+}
+
+// ScriptError is returned when a script cannot be parsed or executed
+type ScriptError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// CompareError is returned when an output row does not match the compare file.
+// Line is the 1-based line in the compare file, counting the header.
+type CompareError struct {
+	File     string
+	Line     int
+	Column   string
+	Expected string
+	Actual   string
+}
+
+func (e *CompareError) Error() string {
+	return fmt.Sprintf("%s:%d: comparison failure in column %s: expected %q, got %q",
+		e.File, e.Line, e.Column, e.Expected, e.Actual)
+}
+
+// LoadScript reads and parses the .tst script at path. Files named by the
+// script are resolved relative to its directory.
+func LoadScript(path string) (*Script, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScript(string(src), path)
+}
+
+// ParseScript parses the text of a .tst script. The path is used to
+// label errors and to resolve the files that the script names.
+func ParseScript(src string, path string) (*Script, error) {
+	s := &Script{path: path, dir: filepath.Dir(path)}
+	toks, err := tokenize(src, path)
+	if err != nil {
+		return nil, err
+	}
+	stmts, rest, err := s.parseBlock(toks)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, &ScriptError{path, rest[0].line, "unexpected }"}
+	}
+	s.stmts = stmts
+	return s, nil
+}
+
+// Run executes the script against a fresh CPU, writing the output file and
+// comparing each output row against the compare file as it is produced.
+// The first mismatch stops the script and is returned as a *CompareError.
+func (s *Script) Run() error {
+	s.cpu = NewCPU()
+	s.columns, s.out, s.cmp, s.row = nil, nil, nil, 0
+	defer func() {
+		if s.outFile != nil {
+			s.outFile.Close()
+			s.outFile = nil
+		}
+	}()
+	return s.exec(s.stmts)
+}
+
+// CPU returns the CPU that the script last ran on
+func (s *Script) CPU() *CPU {
+	return s.cpu
+}
+
+type token struct {
+	line int
+	text string
+}
+
+// tokenize splits a script into words, quoted strings and the punctuation , ; { }
+func tokenize(src string, path string) ([]token, error) {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return nil, &ScriptError{path, line, "unterminated comment"}
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == ',' || c == ';' || c == '{' || c == '}':
+			toks = append(toks, token{line, string(c)})
+			i++
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end == -1 {
+				return nil, &ScriptError{path, line, "unterminated string"}
+			}
+			toks = append(toks, token{line, src[i+1 : i+1+end]})
+			i += end + 2
+		default:
+			start := i
+			for i < len(src) && !strings.ContainsRune(" \t\r\n,;{}\"", rune(src[i])) && !strings.HasPrefix(src[i:], "//") {
+				i++
+			}
+			toks = append(toks, token{line, src[start:i]})
+		}
+	}
+	return toks, nil
+}
+
+// parseBlock parses statements until the end of the tokens or a closing brace,
+// returning the tokens that follow
+func (s *Script) parseBlock(toks []token) ([]statement, []token, error) {
+	var stmts []statement
+	for len(toks) > 0 {
+		tok := toks[0]
+		switch tok.text {
+		case "}":
+			return stmts, toks, nil
+		case ",", ";":
+			toks = toks[1:]
+			continue
+		}
+
+		stmt := statement{line: tok.line, name: tok.text}
+		toks = toks[1:]
+		for len(toks) > 0 && !strings.Contains(",;{}", toks[0].text) {
+			stmt.args = append(stmt.args, toks[0].text)
+			toks = toks[1:]
+		}
+
+		if stmt.name == "repeat" || stmt.name == "while" {
+			if len(toks) == 0 || toks[0].text != "{" {
+				return nil, nil, &ScriptError{s.path, tok.line, fmt.Sprintf("expected { after %s", stmt.name)}
+			}
+			body, rest, err := s.parseBlock(toks[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 {
+				return nil, nil, &ScriptError{s.path, tok.line, fmt.Sprintf("%s block is not closed", stmt.name)}
+			}
+			stmt.body = body
+			toks = rest[1:]
+		} else if len(toks) > 0 && toks[0].text == "{" {
+			return nil, nil, &ScriptError{s.path, toks[0].line, "unexpected {"}
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, toks, nil
+}
+
+func (s *Script) exec(stmts []statement) error {
+	for _, stmt := range stmts {
+		if err := s.execStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Script) execStatement(stmt statement) error {
+	fail := func(format string, args ...interface{}) error {
+		return &ScriptError{s.path, stmt.line, fmt.Sprintf(format, args...)}
+	}
+	switch stmt.name {
+	case "load":
+		if len(stmt.args) != 1 {
+			return fail("load expects a file name")
+		}
+		s.cpu = NewCPU()
+		if err := s.cpu.LoadFile(s.resolve(stmt.args[0])); err != nil {
+			return fail("unable to load %s: %s", stmt.args[0], err)
+		}
+	case "output-file":
+		if len(stmt.args) != 1 {
+			return fail("output-file expects a file name")
+		}
+		f, err := os.Create(s.resolve(stmt.args[0]))
+		if err != nil {
+			return fail("unable to create %s: %s", stmt.args[0], err)
+		}
+		if s.outFile != nil {
+			s.outFile.Close()
+		}
+		s.outFile, s.out = f, f
+	case "compare-to":
+		if len(stmt.args) != 1 {
+			return fail("compare-to expects a file name")
+		}
+		lines, err := readLines(s.resolve(stmt.args[0]))
+		if err != nil {
+			return fail("unable to read %s: %s", stmt.args[0], err)
+		}
+		s.cmp, s.cmpPath = lines, stmt.args[0]
+	case "output-list":
+		s.columns = nil
+		for _, arg := range stmt.args {
+			col, err := parseColumn(arg)
+			if err != nil {
+				return fail("%s", err)
+			}
+			s.columns = append(s.columns, col)
+		}
+		return s.writeRow(s.header(), stmt)
+	case "output":
+		row := "|"
+		for _, col := range s.columns {
+			val, err := s.get(col.name)
+			if err != nil {
+				return fail("%s", err)
+			}
+			row += strings.Repeat(" ", col.left) + col.formatValue(val) + strings.Repeat(" ", col.right) + "|"
+		}
+		return s.writeRow(row, stmt)
+	case "set":
+		if len(stmt.args) != 2 {
+			return fail("set expects a variable and a value")
+		}
+		val, err := parseValue(stmt.args[1])
+		if err != nil {
+			return fail("%s", err)
+		}
+		if err := s.set(stmt.args[0], val); err != nil {
+			return fail("%s", err)
+		}
+	case "tick":
+		// A full clock cycle is a tick followed by a tock; the instruction
+		// is executed on the tock
+	case "tock", "ticktock":
+		s.cpu.Step()
+	case "repeat":
+		n, err := strconv.Atoi(strings.Join(stmt.args, ""))
+		if err != nil || n < 0 {
+			return fail("repeat expects a number of iterations")
+		}
+		for i := 0; i < n; i++ {
+			if err := s.exec(stmt.body); err != nil {
+				return err
+			}
+		}
+	case "while":
+		for {
+			ok, err := s.condition(stmt.args)
+			if err != nil {
+				return fail("%s", err)
+			}
+			if !ok {
+				break
+			}
+			if err := s.exec(stmt.body); err != nil {
+				return err
+			}
+		}
+	case "echo", "clear-echo", "breakpoint", "clear-breakpoints":
+	default:
+		return fail("unknown command %s", stmt.name)
+	}
+	return nil
+}
+
+// writeRow appends a row to the output file and checks it against the compare file
+func (s *Script) writeRow(row string, stmt statement) error {
+	if s.out != nil {
+		if _, err := fmt.Fprintln(s.out, row); err != nil {
+			return &ScriptError{s.path, stmt.line, err.Error()}
+		}
+	}
+	s.row++
+	if s.cmp == nil {
+		return nil
+	}
+	if s.row > len(s.cmp) {
+		return &CompareError{s.cmpPath, s.row, "", "end of file", row}
+	}
+	if err := compareRow(s.cmp[s.row-1], row, s.columns, s.row); err != nil {
+		err.File = s.cmpPath
+		return err
+	}
+	return nil
+}
+
+// compareRow compares the cells of an expected and an actual row. Surrounding
+// whitespace is ignored, and an expected cell made only of * matches anything.
+func compareRow(expected string, actual string, columns []column, line int) *CompareError {
+	exp, act := splitRow(expected), splitRow(actual)
+	for i := 0; i < len(exp) || i < len(act); i++ {
+		e, a, name := "", "", ""
+		if i < len(exp) {
+			e = exp[i]
+		}
+		if i < len(act) {
+			a = act[i]
+		}
+		if i < len(columns) {
+			name = columns[i].name
+		}
+		if e != a && !(e != "" && strings.Trim(e, "*") == "") {
+			return &CompareError{"", line, name, e, a}
+		}
+	}
+	return nil
+}
+
+func splitRow(row string) []string {
+	cells := strings.Split(strings.Trim(strings.TrimSpace(row), "|"), "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// header returns the row naming each column, centered in the column's width
+func (s *Script) header() string {
+	row := "|"
+	for _, col := range s.columns {
+		total := col.left + col.width + col.right
+		name := col.name
+		if len(name) > total {
+			name = name[:total]
+		}
+		left := (total - len(name)) / 2
+		row += strings.Repeat(" ", left) + name + strings.Repeat(" ", total-len(name)-left) + "|"
+	}
+	return row
+}
+
+// parseColumn parses an output-list entry such as RAM[0]%D2.6.2
+func parseColumn(spec string) (column, error) {
+	pct := strings.IndexByte(spec, '%')
+	if pct == -1 {
+		return column{spec, 'D', 1, 6, 1}, nil
+	}
+	col := column{name: spec[:pct]}
+	f := spec[pct+1:]
+	if len(f) < 2 || !strings.ContainsRune("BDXS", rune(f[0])) {
+		return col, fmt.Errorf("%s is not a valid output format", spec)
+	}
+	parts := strings.Split(f[1:], ".")
+	if len(parts) != 3 {
+		return col, fmt.Errorf("%s is not a valid output format", spec)
+	}
+	col.format = f[0]
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return col, fmt.Errorf("%s is not a valid output format", spec)
+		}
+		nums[i] = n
+	}
+	col.left, col.width, col.right = nums[0], nums[1], nums[2]
+	return col, nil
+}
+
+// formatValue renders a value in the column's format: decimals are signed and
+// right-aligned, binary and hex values are zero-padded to the column width,
+// and strings show the value as a right-aligned character, or a blank if it
+// is not printable ASCII
+func (col column) formatValue(val uint16) string {
+	var str string
+	switch col.format {
+	case 'B':
+		str = fmt.Sprintf("%0*b", col.width, val)
+	case 'X':
+		str = fmt.Sprintf("%0*X", col.width, val)
+	case 'S':
+		c := byte(' ')
+		if val >= 0x20 && val < 0x7f {
+			c = byte(val)
+		}
+		str = fmt.Sprintf("%*c", col.width, c)
+	default:
+		str = fmt.Sprintf("%*d", col.width, int16(val))
+	}
+	if len(str) > col.width {
+		str = str[len(str)-col.width:]
+	}
+	return str
+}
+
+// parseValue parses a literal such as 3, -1, %D-1, %X7FFF or %B0101
+func parseValue(str string) (uint16, error) {
+	base, digits := 10, str
+	if strings.HasPrefix(str, "%") && len(str) > 1 {
+		switch str[1] {
+		case 'B':
+			base = 2
+		case 'X':
+			base = 16
+		case 'D':
+		default:
+			return 0, fmt.Errorf("%s is not a valid value", str)
+		}
+		digits = str[2:]
+	}
+	n, err := strconv.ParseInt(digits, base, 32)
+	if err != nil || n < -32768 || n > 65535 {
+		return 0, fmt.Errorf("%s is not a valid value", str)
+	}
+	return uint16(n), nil
+}
+
+// condition evaluates a while condition such as RAM[0] <> 0
+func (s *Script) condition(args []string) (bool, error) {
+	expr := strings.Join(args, "")
+	for _, op := range []string{"<>", "<=", ">=", "=", "<", ">"} {
+		i := strings.Index(expr, op)
+		if i == -1 {
+			continue
+		}
+		lhs, err := s.get(expr[:i])
+		if err != nil {
+			return false, err
+		}
+		rhs, err := parseValue(expr[i+len(op):])
+		if err != nil {
+			return false, err
+		}
+		l, r := int16(lhs), int16(rhs)
+		switch op {
+		case "<>":
+			return l != r, nil
+		case "<=":
+			return l <= r, nil
+		case ">=":
+			return l >= r, nil
+		case "=":
+			return l == r, nil
+		case "<":
+			return l < r, nil
+		default:
+			return l > r, nil
+		}
+	}
+	return false, fmt.Errorf("%s is not a valid condition", expr)
+}
+
+// get reads a script variable: A, D, PC, time, RAM[n] or ROM[n]
+func (s *Script) get(name string) (uint16, error) {
+	switch name {
+	case "A":
+		return s.cpu.A, nil
+	case "D":
+		return s.cpu.D, nil
+	case "PC":
+		return s.cpu.PC, nil
+	case "time":
+		return uint16(s.cpu.Cycles), nil
+	}
+	mem, addr, err := s.memory(name)
+	if err != nil {
+		return 0, err
+	}
+	return mem[addr], nil
+}
+
+// set writes a script variable: A, D, PC, RAM[n] or ROM[n]
+func (s *Script) set(name string, val uint16) error {
+	switch name {
+	case "A":
+		s.cpu.A = val
+	case "D":
+		s.cpu.D = val
+	case "PC":
+		s.cpu.PC = val
+	default:
+		mem, addr, err := s.memory(name)
+		if err != nil {
+			return err
+		}
+		mem[addr] = val
+	}
+	return nil
+}
+
+func (s *Script) memory(name string) ([]uint16, int, error) {
+	var mem []uint16
+	switch {
+	case strings.HasPrefix(name, "RAM[") && strings.HasSuffix(name, "]"):
+		mem = s.cpu.RAM[:]
+	case strings.HasPrefix(name, "ROM[") && strings.HasSuffix(name, "]"):
+		mem = s.cpu.ROM[:]
+	default:
+		return nil, 0, fmt.Errorf("unknown variable %s", name)
+	}
+	addr, err := strconv.Atoi(name[4 : len(name)-1])
+	if err != nil || addr < 0 || addr >= len(mem) {
+		return nil, 0, fmt.Errorf("%s is not a valid address", name)
+	}
+	return mem, addr, nil
+}
+
+func (s *Script) resolve(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
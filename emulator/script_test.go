@@ -0,0 +1,96 @@
+package emulator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestScript(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Output formatting", func() {
+		g.It("Should parse output-list formats", func() {
+			col, err := parseColumn("RAM[0]%D2.6.2")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(col).Equal(column{"RAM[0]", 'D', 2, 6, 2})
+			_, err = parseColumn("RAM[0]%Q2.6.2")
+			g.Assert(err != nil).IsTrue()
+			_, err = parseColumn("RAM[0]%D2.6")
+			g.Assert(err != nil).IsTrue()
+		})
+		g.It("Should format values in each base", func() {
+			g.Assert(column{"D", 'D', 1, 6, 1}.formatValue(0xffff)).Equal("    -1")
+			g.Assert(column{"D", 'X', 1, 4, 1}.formatValue(0x3a)).Equal("003A")
+			g.Assert(column{"D", 'B', 1, 16, 1}.formatValue(5)).Equal("0000000000000101")
+			g.Assert(column{"D", 'S', 1, 3, 1}.formatValue('A')).Equal("  A")
+			g.Assert(column{"D", 'S', 1, 1, 1}.formatValue(0x8000)).Equal(" ")
+		})
+		g.It("Should parse values in each base", func() {
+			for str, expected := range map[string]uint16{"3": 3, "-1": 0xffff, "%D-2": 0xfffe, "%X7FFF": 0x7fff, "%B101": 5} {
+				val, err := parseValue(str)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(val).Equal(expected)
+			}
+		})
+	})
+
+	g.Describe("Running scripts", func() {
+		g.It("Should pass the Max test", func() {
+			s, err := LoadScript("../test/Max.tst")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s.Run() == nil).IsTrue()
+			out, _ := ioutil.ReadFile("../test/Max.out")
+			cmp, _ := ioutil.ReadFile("../test/Max.cmp")
+			g.Assert(string(out)).Equal(string(cmp))
+		})
+		g.It("Should pass the Rect test using a while loop", func() {
+			s, err := LoadScript("../test/Rect.tst")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s.Run() == nil).IsTrue()
+		})
+		g.It("Should report the first mismatching row and column", func() {
+			dir, _ := ioutil.TempDir("", "script")
+			defer os.RemoveAll(dir)
+			cmp := "|  RAM[0]  |  RAM[1]  |  RAM[2]  |\n|       3  |       5  |       5  |\n|   23456  |   12345  |   12345  |\n"
+			ioutil.WriteFile(filepath.Join(dir, "Max.cmp"), []byte(cmp), 0644)
+			src, _ := ioutil.ReadFile("../test/Max.tst")
+			abs, _ := filepath.Abs("../test/Max.hack")
+			ioutil.WriteFile(filepath.Join(dir, "Max.hack"), mustRead(abs), 0644)
+
+			s, err := ParseScript(string(src), filepath.Join(dir, "Max.tst"))
+			g.Assert(err == nil).IsTrue()
+			err = s.Run()
+			cmpErr, ok := err.(*CompareError)
+			g.Assert(ok).IsTrue()
+			g.Assert(cmpErr.Line).Equal(3)
+			g.Assert(cmpErr.Column).Equal("RAM[2]")
+			g.Assert(cmpErr.Expected).Equal("12345")
+			g.Assert(cmpErr.Actual).Equal("23456")
+		})
+		g.It("Should report unknown commands with their line", func() {
+			s, err := ParseScript("load Max.hack;\n\nfrobnicate;", "../test/Bad.tst")
+			g.Assert(err == nil).IsTrue()
+			err = s.Run()
+			scriptErr, ok := err.(*ScriptError)
+			g.Assert(ok).IsTrue()
+			g.Assert(scriptErr.Line).Equal(3)
+		})
+		g.It("Should reject unbalanced blocks", func() {
+			_, err := ParseScript("repeat 3 { ticktock;", "Bad.tst")
+			g.Assert(err != nil).IsTrue()
+			_, err = ParseScript("ticktock; }", "Bad.tst")
+			g.Assert(err != nil).IsTrue()
+		})
+	})
+}
+
+func mustRead(path string) []byte {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
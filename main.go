@@ -1,21 +1,796 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 
+	"github.com/BarthesSimpson/assembler/asm"
+	"github.com/BarthesSimpson/assembler/emulator"
 	log "github.com/sirupsen/logrus"
 )
 
+// version is the release of the assembler, which builds can set with
+// -ldflags "-X main.version=v1.2.0"
+var version = "dev"
+
+// Exit codes other than 0
+const (
+	// exitFailure means the source has errors, or a test or program failed
+	exitFailure = 1
+	// exitUsage means the command line is not valid
+	exitUsage = 2
+)
+
+// command is a subcommand of the CLI. aliases are older names that are still accepted.
+type command struct {
+	name    string
+	aliases []string
+	args    string
+	summary string
+	run     func(c command, args []string)
+}
+
+func commands() []command {
+	return []command{
+		{"asm", nil, "[flags] <file.asm>... | -", "assemble source files into a ROM image or object files (the default)", assembleFiles},
+		{"disasm", []string{"disassemble"}, "[flags] <file.hack> | -", "convert a .hack ROM image back into assembly", disassemble},
+		{"run", []string{"emulate"}, "[flags] <file.hack | file.asm>", "run a program on the built-in CPU emulator", emulate},
+		{"fmt", nil, "[flags] [<file.asm>... | -]", "rewrite assembly source in a canonical layout", formatFiles},
+		{"lint", nil, "[flags] <file.asm>...", "check assembly source for problems without writing any output", lint},
+		{"verify", nil, "[flags] <file.asm> [<expected.hack>]", "assemble a program and compare it with a reference .hack file", verify},
+		{"link", nil, "[flags] <object.o>...", "combine object files into a ROM image", link},
+		{"test", nil, "[flags] <script.tst>...", "run CPUEmulator test scripts", runScripts},
+	}
+}
+
 func main() {
-	if len(os.Args) != 2 {
-		log.Fatal("Usage: assemble <filepath>")
+	cmds := commands()
+	cmd, args := cmds[0], os.Args[1:]
+	if len(args) > 0 && args[0] == "help" {
+		printUsage(os.Stdout)
+		return
+	}
+	if len(args) > 0 {
+		for _, c := range cmds {
+			if args[0] == c.name || contains(c.aliases, args[0]) {
+				cmd, args = c, args[1:]
+				break
+			}
+		}
+	}
+	cmd.run(cmd, args)
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	cmds := commands()
+	fmt.Fprintf(w, "Usage: assembler [asm] %s\n       assembler <command> [flags] <args>\n\nCommands:\n", cmds[0].args)
+	for _, c := range cmds {
+		fmt.Fprintf(w, "  %-7s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun assembler <command> -h for the flags of a command.\n")
+}
+
+// newFlagSet creates the flag set of a command, which prints the usage of
+// the command for -h
+func newFlagSet(c command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: assembler %s %s\n\nThe %s command will %s.\n\nFlags:\n", c.name, c.args, c.name, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags adds -v and -quiet to the flags of a command, parses its
+// arguments and sets the log level
+func parseFlags(fs *flag.FlagSet, args []string) {
+	verbose := fs.Bool("v", false, "also log debugging detail")
+	quiet := fs.Bool("quiet", false, "only log warnings and errors")
+	fs.Parse(args)
+	switch {
+	case *verbose && *quiet:
+		usageError(fs, "-v and -quiet cannot be used together")
+	case *verbose:
+		log.SetLevel(log.DebugLevel)
+	case *quiet:
+		log.SetLevel(log.WarnLevel)
+	}
+}
+
+// usageError reports a command line that is not valid, followed by the
+// usage of the command, and exits
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) {
+	fmt.Fprintf(fs.Output(), format+"\n\n", args...)
+	fs.Usage()
+	os.Exit(exitUsage)
+}
+
+// assembleFiles assembles the input files into a single ROM image, or each
+// one into an object file with -c. In batch mode, which directories and
+// glob patterns imply, each file is assembled on its own and in parallel.
+func assembleFiles(c command, args []string) {
+	fs := newFlagSet(c)
+	fs.Usage = func() {
+		printUsage(fs.Output())
+		fmt.Fprintf(fs.Output(), "\nFlags of asm:\n")
+		fs.PrintDefaults()
+	}
+	outpath := fs.String("o", "", "write the output to this path, or to standard output if it is -; "+
+		"by default it is named after the first input, and - reads standard input and writes standard output")
+	format := fs.String("format", "hack", "output format: "+strings.Join(asm.FormatStrings, ", "))
+	listing := fs.Bool("listing", false, "also write a .lst listing next to the output")
+	symbols := fs.Bool("symbols", false, "also write a .sym file next to the output, with the address of each label, variable and constant")
+	debugInfo := fs.Bool("debug-info", false, "also write .dbg.json debug info next to the output")
+	includes := fs.String("I", "", "comma-separated directories to search for .include files")
+	object := fs.Bool("c", false, "write a relocatable .o object file for each input instead of a ROM image")
+	data := fs.String("data", "prologue", "how to initialize .word and .string data: "+strings.Join(asm.DataStrategyStrings, ", ")+
+		"; image writes the RAM image next to the output, as Prog.ram.hack for Prog.hack")
+	negatives := fs.Bool("expand-negatives", false, "load negative literals such as @-5 with @4 and A=!A instead of failing")
+	ramLimits := fs.String("ram-limits", "", "comma-separated name=address[:warning|error] limits for variables, or none "+
+		"(default stack=256:warning,screen=16384:error)")
+	shadowing := fs.Bool("allow-shadowing", false, "warn about labels that redefine other labels or predefined symbols instead of failing")
+	labelUse := fs.Bool("strict-label-use", false, "fail on labels used as variables before their definition instead of warning")
+	batch := fs.Bool("batch", false, "assemble each input as a separate program; implied by directories and glob patterns")
+	workers := fs.Int("j", runtime.NumCPU(), "number of files to assemble at once in batch mode")
+	outdir := fs.String("outdir", "", "in batch mode, write the outputs into this directory instead of next to each input")
+	showVersion := fs.Bool("version", false, "print the version and exit")
+	parseFlags(fs, args)
+	if *showVersion {
+		fmt.Println("assembler", version)
+		return
+	}
+	f := asm.EnumValFromString(asm.FormatStrings, *format)
+	if f == -1 {
+		usageError(fs, "%s is not a valid output format", *format)
+	}
+	d := asm.EnumValFromString(asm.DataStrategyStrings, *data)
+	if d == -1 {
+		usageError(fs, "%s is not a valid data strategy", *data)
+	}
+	var limits []asm.RAMLimit
+	if *ramLimits != "" {
+		var err error
+		if limits, err = asm.ParseRAMLimits(*ramLimits); err != nil {
+			usageError(fs, "%s", err)
+		}
+	}
+	if fs.NArg() < 1 {
+		usageError(fs, "no input files")
+	}
+	if *object && fs.NArg() > 1 && *outpath != "" {
+		usageError(fs, "-o cannot be used with -c and several input files")
+	}
+	opts := asm.Options{Format: asm.Format(f), IncludePaths: splitList(*includes), Object: *object,
+		Data: asm.DataStrategy(d), ExpandNegatives: *negatives, RAMLimits: limits, AllowShadowing: *shadowing,
+		StrictLabelUse: *labelUse}
+	outs := outputs{*outpath, *listing, *symbols, *debugInfo}
+	if *batch || *outdir != "" || isBatch(fs.Args()) {
+		switch {
+		case *outpath != "":
+			usageError(fs, "-o cannot be used in batch mode; use -outdir")
+		case contains(fs.Args(), asm.StdinPath):
+			usageError(fs, "standard input cannot be assembled in batch mode")
+		case *workers < 1:
+			usageError(fs, "-j must be at least 1")
+		}
+		inputs, err := findInputs(fs.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(inputs) == 0 {
+			usageError(fs, "no .asm files found")
+		}
+		outpaths, err := batchOutputs(inputs, opts, *outdir)
+		if err != nil {
+			usageError(fs, "%s", err)
+		}
+		if !assembleBatch(inputs, outpaths, opts, outs, *workers) {
+			os.Exit(exitFailure)
+		}
+		return
+	}
+	failed := false
+	if !*object {
+		failed = !assemble(fs, fs.Args(), opts, outs)
+	} else {
+		for _, inpath := range fs.Args() {
+			failed = !assemble(fs, []string{inpath}, opts, outs) || failed
+		}
+	}
+	if failed {
+		os.Exit(exitFailure)
+	}
+}
+
+// outputs are the files that assemble writes. path is the ROM image or
+// object file, which the other files are named after.
+type outputs struct {
+	path      string
+	listing   bool
+	symbols   bool
+	debugInfo bool
+}
+
+// assemble writes a single ROM image or object file built from every input
+// file, named after the first one unless a path is given, and returns
+// whether it succeeded. If the only input is standard input, the output is
+// written to standard output.
+func assemble(fs *flag.FlagSet, inpaths []string, opts asm.Options, outs outputs) bool {
+	outpath := outs.path
+	if outpath == "" {
+		outpath = defaultOutput(inpaths[0], opts)
+		if len(inpaths) == 1 && inpaths[0] == asm.StdinPath {
+			outpath = asm.StdinPath
+		}
+	}
+	// The other files are named after the output, or after the input when
+	// the output is standard output
+	base := trimExt(outpath)
+	if outpath == asm.StdinPath {
+		base = trimExt(inpaths[0])
+	}
+	if base == asm.StdinPath && (outs.listing || outs.symbols || outs.debugInfo || opts.Data == asm.DataImage) {
+		usageError(fs, "-listing, -symbols, -debug-info and -data image need -o or an input file to name their output after")
+	}
+	b := newBuild(outpath, base, opts, outs)
+	res, err := asm.AssembleFiles(inpaths, &b.out, b.opts)
+	return b.finish(res, err)
+}
+
+// defaultOutput names the output of an input file after it
+func defaultOutput(inpath string, opts asm.Options) string {
+	if opts.Object {
+		return trimExt(inpath) + ".o"
+	}
+	return trimExt(inpath) + asm.FormatExtensions[opts.Format]
+}
+
+// build holds the outputs of one run of the assembler until they are written.
+// base is the path that the files other than the output are named after.
+type build struct {
+	outpath string
+	base    string
+	outs    outputs
+	opts    asm.Options
+	out     bytes.Buffer
+	lst     bytes.Buffer
+	dbg     bytes.Buffer
+	ram     bytes.Buffer
+}
+
+// newBuild creates a build, and sets the options to write into its buffers
+func newBuild(outpath string, base string, opts asm.Options, outs outputs) *build {
+	b := &build{outpath: outpath, base: base, outs: outs}
+	if opts.Data == asm.DataImage {
+		opts.RAMImage = &b.ram
+	}
+	if outs.listing {
+		opts.Listing = &b.lst
+	}
+	if outs.debugInfo {
+		opts.DebugInfo = &b.dbg
+	}
+	b.opts = opts
+	return b
+}
+
+// finish reports the diagnostics of a run and, if it succeeded, writes its
+// files. It returns whether the run succeeded.
+func (b *build) finish(res *asm.Result, err error) bool {
+	if res != nil {
+		res.Diagnostics.Print(os.Stderr)
+	}
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	if err := writeOutput(b.outpath, b.out.Bytes()); err != nil {
+		log.Fatalf("Unable to write output file: %s", err)
+	}
+	log.Infof("%s: %s", outputName(b.outpath), res.Usage)
+	if b.opts.RAMImage != nil && !b.opts.Object {
+		if err := ioutil.WriteFile(b.base+".ram"+asm.FormatExtensions[b.opts.Format], b.ram.Bytes(), 0644); err != nil {
+			log.Fatalf("Unable to write RAM image: %s", err)
+		}
+	}
+	if b.outs.listing {
+		if err := ioutil.WriteFile(b.base+".lst", b.lst.Bytes(), 0644); err != nil {
+			log.Fatalf("Unable to write listing file: %s", err)
+		}
+	}
+	if b.outs.symbols {
+		var sym bytes.Buffer
+		res.Symbols.WriteSymbols(&sym)
+		if err := ioutil.WriteFile(b.base+".sym", sym.Bytes(), 0644); err != nil {
+			log.Fatalf("Unable to write symbol file: %s", err)
+		}
+	}
+	if b.outs.debugInfo {
+		if err := ioutil.WriteFile(b.base+".dbg.json", b.dbg.Bytes(), 0644); err != nil {
+			log.Fatalf("Unable to write debug info file: %s", err)
+		}
+	}
+	return true
+}
+
+// input is a source file found for batch mode. rel is its path relative to
+// the directory argument it was found in, or its name if it was given as a
+// file or matched a glob pattern.
+type input struct {
+	path string
+	rel  string
+}
+
+// isBatch returns whether any argument is a directory or a glob pattern
+func isBatch(args []string) bool {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") {
+			return true
+		}
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// findInputs expands the arguments of batch mode into source files: a
+// directory stands for every .asm file below it, and a glob pattern for the
+// files it matches
+func findInputs(args []string) ([]input, error) {
+	var inputs []input
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid pattern: %s", arg, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(arg, "*?[") {
+			return nil, fmt.Errorf("%s does not exist", arg)
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				inputs = append(inputs, input{path, filepath.Base(path)})
+				continue
+			}
+			root := path
+			err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || filepath.Ext(path) != ".asm" {
+					return err
+				}
+				rel, err := filepath.Rel(root, path)
+				inputs = append(inputs, input{path, rel})
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// batchOutputs names the output of each input of a batch, next to the input
+// or below outdir in the same layout as the inputs. Two inputs that would
+// write the same output, such as Main.asm in two directories given with
+// -outdir, are an error.
+func batchOutputs(inputs []input, opts asm.Options, outdir string) ([]string, error) {
+	outpaths := make([]string, len(inputs))
+	seen := map[string]string{}
+	for i, in := range inputs {
+		outpath := defaultOutput(in.path, opts)
+		if outdir != "" {
+			outpath = filepath.Join(outdir, defaultOutput(in.rel, opts))
+		}
+		outpath = filepath.Clean(outpath)
+		if prev, ok := seen[outpath]; ok {
+			return nil, fmt.Errorf("%s and %s would both be written to %s", prev, in.path, outpath)
+		}
+		seen[outpath] = in.path
+		outpaths[i] = outpath
+	}
+	return outpaths, nil
+}
+
+// assembleBatch assembles each input as a separate program, up to workers at
+// a time, and writes it to the matching output path. The diagnostics of each
+// file are reported in order once every file has been assembled, followed by
+// a summary. It returns whether every file succeeded.
+func assembleBatch(inputs []input, outpaths []string, opts asm.Options, outs outputs, workers int) bool {
+	builds := make([]*build, len(inputs))
+	jobs := make([]asm.BatchJob, len(inputs))
+	for i, in := range inputs {
+		outpath := outpaths[i]
+		builds[i] = newBuild(outpath, trimExt(outpath), opts, outs)
+		jobs[i] = asm.BatchJob{Path: in.path, Out: &builds[i].out, Options: builds[i].opts}
+	}
+	results := asm.AssembleBatch(jobs, workers)
+	for i, r := range results {
+		if r.Err == nil {
+			if err := os.MkdirAll(filepath.Dir(builds[i].outpath), 0755); err != nil {
+				log.Fatalf("Unable to create output directory: %s", err)
+			}
+		}
+		builds[i].finish(r.Result, r.Err)
+	}
+	summary := asm.SummarizeBatch(results)
+	if summary.Failed > 0 {
+		log.Error(summary)
+		return false
+	}
+	log.Info(summary)
+	return true
+}
+
+// lint assembles the input files without writing any output, and fails if
+// they have errors, or warnings with -strict
+func lint(c command, args []string) {
+	fs := newFlagSet(c)
+	includes := fs.String("I", "", "comma-separated directories to search for .include files")
+	object := fs.Bool("c", false, "check each file as a separate object file, rather than the files together as one program")
+	negatives := fs.Bool("expand-negatives", false, "accept negative literals such as @-5")
+	ramLimits := fs.String("ram-limits", "", "comma-separated name=address[:warning|error] limits for variables, or none")
+	shadowing := fs.Bool("allow-shadowing", false, "warn about labels that redefine other labels or predefined symbols instead of failing")
+	labelUse := fs.Bool("strict-label-use", false, "fail on labels used as variables before their definition instead of warning")
+	strict := fs.Bool("strict", false, "fail on warnings as well as errors")
+	parseFlags(fs, args)
+	var limits []asm.RAMLimit
+	if *ramLimits != "" {
+		var err error
+		if limits, err = asm.ParseRAMLimits(*ramLimits); err != nil {
+			usageError(fs, "%s", err)
+		}
+	}
+	if fs.NArg() < 1 {
+		usageError(fs, "no input files")
+	}
+	opts := asm.Options{IncludePaths: splitList(*includes), Object: *object, ExpandNegatives: *negatives,
+		RAMLimits: limits, AllowShadowing: *shadowing, StrictLabelUse: *labelUse}
+	programs := [][]string{fs.Args()}
+	if *object {
+		programs = nil
+		for _, inpath := range fs.Args() {
+			programs = append(programs, []string{inpath})
+		}
+	}
+	errors, warnings := 0, 0
+	for _, inpaths := range programs {
+		res, err := asm.AssembleFiles(inpaths, ioutil.Discard, opts)
+		if _, ok := err.(*asm.AssemblyError); err != nil && !ok {
+			log.Fatalf("Unable to read source: %s", err)
+		}
+		res.Diagnostics.Print(os.Stderr)
+		for _, d := range res.Diagnostics.Items() {
+			switch d.Severity {
+			case asm.SeverityError:
+				errors++
+			case asm.SeverityWarning:
+				warnings++
+			}
+		}
+	}
+	log.Infof("%d error(s), %d warning(s)", errors, warnings)
+	if errors > 0 || (*strict && warnings > 0) {
+		os.Exit(exitFailure)
+	}
+}
+
+// verify assembles a program and compares it word by word with a reference
+// ROM image, which by default is the file next to it named like
+// ProgExpected.hack, and fails if they differ
+func verify(c command, args []string) {
+	fs := newFlagSet(c)
+	includes := fs.String("I", "", "comma-separated directories to search for .include files")
+	data := fs.String("data", "prologue", "how to initialize .word and .string data: "+strings.Join(asm.DataStrategyStrings, ", "))
+	negatives := fs.Bool("expand-negatives", false, "load negative literals such as @-5 with @4 and A=!A instead of failing")
+	shadowing := fs.Bool("allow-shadowing", false, "warn about labels that redefine other labels or predefined symbols instead of failing")
+	labelUse := fs.Bool("strict-label-use", false, "fail on labels used as variables before their definition instead of warning")
+	parseFlags(fs, args)
+	d := asm.EnumValFromString(asm.DataStrategyStrings, *data)
+	if d == -1 {
+		usageError(fs, "%s is not a valid data strategy", *data)
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		usageError(fs, "expected a program and at most one reference file")
+	}
+	inpath, refpath := fs.Arg(0), fs.Arg(1)
+	if refpath == "" {
+		if inpath == asm.StdinPath {
+			usageError(fs, "a reference file is needed to verify standard input")
+		}
+		refpath = trimExt(inpath) + "Expected.hack"
+	}
+	src, err := readInput(inpath)
+	if err != nil {
+		log.Fatalf("Unable to read input file: %s", err)
+	}
+	ref, err := readInput(refpath)
+	if err != nil {
+		log.Fatalf("Unable to read reference file: %s", err)
+	}
+
+	opts := asm.VerifyOptions{Options: asm.Options{Filename: inputName(inpath), IncludePaths: splitList(*includes),
+		Data: asm.DataStrategy(d), ExpandNegatives: *negatives, AllowShadowing: *shadowing, StrictLabelUse: *labelUse},
+		Reference: inputName(refpath)}
+	res, err := asm.Verify(bytes.NewReader(src), bytes.NewReader(ref), opts)
+	if res != nil {
+		res.Diagnostics.Print(os.Stderr)
+	}
+	if err != nil {
+		log.Error(err)
+		os.Exit(exitFailure)
+	}
+	log.Infof("%s matches %s: %d word(s)", inputName(inpath), inputName(refpath), res.Instructions)
+}
+
+// formatFiles rewrites assembly source in the canonical layout, reading
+// standard input if no files are given
+func formatFiles(c command, args []string) {
+	fs := newFlagSet(c)
+	write := fs.Bool("w", false, "write the result back to each file instead of to standard output")
+	list := fs.Bool("l", false, "list the files whose formatting differs instead of printing them")
+	parseFlags(fs, args)
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{asm.StdinPath}
+	}
+	if *write && contains(paths, asm.StdinPath) {
+		usageError(fs, "-w cannot be used with standard input")
+	}
+	for _, path := range paths {
+		src, err := readInput(path)
+		if err != nil {
+			log.Fatalf("Unable to read input file: %s", err)
+		}
+		var out bytes.Buffer
+		if err := asm.FormatSource(bytes.NewReader(src), &out); err != nil {
+			log.Fatalf("Unable to format %s: %s", inputName(path), err)
+		}
+		switch {
+		case *list:
+			if !bytes.Equal(src, out.Bytes()) {
+				fmt.Println(inputName(path))
+			}
+		case *write:
+			if !bytes.Equal(src, out.Bytes()) {
+				if err := ioutil.WriteFile(path, out.Bytes(), 0644); err != nil {
+					log.Fatalf("Unable to write output file: %s", err)
+				}
+			}
+		default:
+			os.Stdout.Write(out.Bytes())
+		}
+	}
+}
+
+// link combines object files into a ROM image, named after the first object unless -o is given
+func link(c command, args []string) {
+	fs := newFlagSet(c)
+	format := fs.String("format", "hack", "output format: "+strings.Join(asm.FormatStrings, ", "))
+	outpath := fs.String("o", "", "write the ROM image to this path, or to standard output if it is -")
+	parseFlags(fs, args)
+	f := asm.EnumValFromString(asm.FormatStrings, *format)
+	if f == -1 {
+		usageError(fs, "%s is not a valid output format", *format)
+	}
+	if fs.NArg() < 1 {
+		usageError(fs, "no object files")
+	}
+
+	var objs []*asm.Object
+	for _, inpath := range fs.Args() {
+		infile, err := os.Open(inpath)
+		if err != nil {
+			log.Fatalf("Unable to open object file: %s", err)
+		}
+		obj, err := asm.LoadObject(infile)
+		infile.Close()
+		if err != nil {
+			log.Fatalf("Unable to read object file %s: %s", inpath, err)
+		}
+		objs = append(objs, obj)
+	}
+	if *outpath == "" {
+		*outpath = trimExt(fs.Arg(0)) + asm.FormatExtensions[f]
+	}
+
+	var out bytes.Buffer
+	opts := asm.LinkOptions{Filename: outputName(*outpath), Format: asm.Format(f)}
+	diags, err := asm.Link(objs, &out, opts)
+	diags.Print(os.Stderr)
+	if err != nil {
+		log.Error(err)
+		os.Exit(exitFailure)
+	}
+	if err := writeOutput(*outpath, out.Bytes()); err != nil {
+		log.Fatalf("Unable to write output file: %s", err)
+	}
+}
+
+// disassemble converts a .hack file back into assembly, written to stdout unless -o is given
+func disassemble(c command, args []string) {
+	fs := newFlagSet(c)
+	labels := fs.Bool("labels", false, "synthesize labels for jump targets")
+	annotate := fs.Bool("annotate", false, "annotate A-values that refer to predefined symbols")
+	outpath := fs.String("o", asm.StdinPath, "write the assembly to this path, or to standard output if it is -")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		usageError(fs, "expected exactly one input file")
+	}
+
+	inpath := fs.Arg(0)
+	src, err := readInput(inpath)
+	if err != nil {
+		log.Fatalf("Unable to read input file: %s", err)
 	}
 
-	inpath := os.Args[1]
-	fname := strings.Split(inpath, ".")[0]
-	outpath := fmt.Sprintf("%s.hack", fname)
-	asm := Assembler{inpath, outpath, Code{}, InitializeSymbolTable(), nil}
-	asm.Convert()
+	var out bytes.Buffer
+	opts := asm.DisassembleOptions{Filename: inputName(inpath), Labels: *labels, Annotate: *annotate}
+	diags, err := asm.Disassemble(bytes.NewReader(src), &out, opts)
+	diags.Print(os.Stderr)
+	if err != nil {
+		log.Error(err)
+		os.Exit(exitFailure)
+	}
+	if err := writeOutput(*outpath, out.Bytes()); err != nil {
+		log.Fatalf("Unable to write output file: %s", err)
+	}
+}
+
+// emulate runs a .hack or .asm program on the built-in CPU and dumps the requested RAM ranges
+func emulate(c command, args []string) {
+	fs := newFlagSet(c)
+	cycles := fs.Int("cycles", 1000000, "maximum number of instructions to execute (0 for no limit)")
+	set := fs.String("set", "", "comma separated addr=value pairs to store in RAM before running")
+	ram := fs.String("ram", "", "comma separated RAM addresses or from-to ranges to dump after running")
+	image := fs.String("load-ram", "", "a .hack RAM image, such as one written with -data image, to load before running")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		usageError(fs, "expected exactly one program")
+	}
+
+	cpu := emulator.NewCPU()
+	if err := cpu.LoadFile(fs.Arg(0)); err != nil {
+		if asmErr, ok := err.(*asm.AssemblyError); ok {
+			asmErr.Print(os.Stderr)
+		}
+		log.Errorf("Unable to load program: %s", err)
+		os.Exit(exitFailure)
+	}
+	if *image != "" {
+		f, err := os.Open(*image)
+		if err != nil {
+			log.Fatalf("Unable to open RAM image: %s", err)
+		}
+		err = cpu.LoadRAM(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Unable to load RAM image: %s", err)
+		}
+	}
+	for _, pair := range splitList(*set) {
+		kv := strings.SplitN(pair, "=", 2)
+		addr, err := strconv.Atoi(kv[0])
+		if err != nil || len(kv) != 2 || addr < 0 || addr >= emulator.RAMSize {
+			usageError(fs, "%s is not a valid addr=value pair", pair)
+		}
+		val, err := strconv.ParseInt(kv[1], 10, 32)
+		if err != nil {
+			usageError(fs, "%s is not a valid addr=value pair", pair)
+		}
+		cpu.RAM[addr] = uint16(val)
+	}
+
+	halt := cpu.Run(*cycles)
+	log.Infof("Stopped after %d cycles: %s", cpu.Cycles, halt)
+
+	for _, rng := range splitList(*ram) {
+		bounds := strings.SplitN(rng, "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		to := from
+		if err == nil && len(bounds) == 2 {
+			to, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil {
+			usageError(fs, "%s is not a valid RAM range", rng)
+		}
+		if err := cpu.Dump(os.Stdout, from, to); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// runScripts runs each CPUEmulator test script, reporting the ones that fail
+func runScripts(c command, args []string) {
+	fs := newFlagSet(c)
+	parseFlags(fs, args)
+	if fs.NArg() == 0 {
+		usageError(fs, "no test scripts")
+	}
+	failed := 0
+	for _, path := range fs.Args() {
+		s, err := emulator.LoadScript(path)
+		if err == nil {
+			err = s.Run()
+		}
+		if err != nil {
+			log.Errorf("FAIL %s: %s", path, err)
+			failed++
+			continue
+		}
+		log.Infof("PASS %s", path)
+	}
+	if failed > 0 {
+		os.Exit(exitFailure)
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// trimExt removes the extension from the last element of a path, so that
+// ./dir.v2/Prog.asm becomes ./dir.v2/Prog
+func trimExt(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// inputName returns the name of an input path in messages
+func inputName(path string) string {
+	if path == asm.StdinPath {
+		return "<stdin>"
+	}
+	return path
+}
+
+// outputName returns the name of an output path in messages
+func outputName(path string) string {
+	if path == asm.StdinPath {
+		return "<stdout>"
+	}
+	return path
+}
+
+// readInput reads a file, or standard input if the path is -
+func readInput(path string) ([]byte, error) {
+	if path == asm.StdinPath {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// writeOutput writes a file, or standard output if the path is -
+func writeOutput(path string, data []byte) error {
+	if path == asm.StdinPath {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/BarthesSimpson/assembler/asm"
+	. "github.com/franela/goblin"
+)
+
+func TestBatchOutputs(t *testing.T) {
+	g := Goblin(t)
+	g.Describe("Batch outputs", func() {
+		inputs := []input{{"a/Main.asm", "Main.asm"}, {"a/lib/Math.asm", "lib/Math.asm"}, {"b/Main.asm", "Main.asm"}}
+		g.It("Should write each output next to its input", func() {
+			outpaths, err := batchOutputs(inputs, asm.Options{}, "")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(outpaths).Equal([]string{"a/Main.hack", "a/lib/Math.hack", "b/Main.hack"})
+		})
+		g.It("Should keep the layout of the inputs below the output directory", func() {
+			outpaths, err := batchOutputs(inputs[:2], asm.Options{Object: true}, "out")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(outpaths).Equal([]string{"out/Main.o", "out/lib/Math.o"})
+		})
+		g.It("Should fail if two inputs would write the same output", func() {
+			_, err := batchOutputs(inputs, asm.Options{}, "out")
+			g.Assert(err == nil).IsFalse()
+			g.Assert(err.Error()).Equal("a/Main.asm and b/Main.asm would both be written to out/Main.hack")
+			_, err = batchOutputs([]input{{"a/Main.asm", "Main.asm"}, {"./a/Main.asm", "Main.asm"}}, asm.Options{}, "")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
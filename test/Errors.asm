@@ -0,0 +1,7 @@
+// Every instruction below except the first contains a mistake
+   @2
+   P=D+A
+D=;JGT
+   D;JJJ   // bad jump
+	AM=M+2
+@40000
@@ -0,0 +1,26 @@
+// This file is part of www.nand2tetris.org
+// and the book "The Elements of Computing Systems"
+// by Nisan and Schocken, MIT Press.
+// File name: projects/06/max/Max.tst
+
+load Max.hack,
+output-file Max.out,
+compare-to Max.cmp,
+output-list RAM[0]%D2.6.2 RAM[1]%D2.6.2 RAM[2]%D2.6.2;
+
+set RAM[0] 3,   // Set test arguments
+set RAM[1] 5,
+set RAM[2] 0;
+repeat 14 {
+  ticktock;
+}
+output;
+
+set PC 0,
+set RAM[0] 23456,  // Set test arguments
+set RAM[1] 12345,
+set RAM[2] 0;
+repeat 14 {
+  ticktock;
+}
+output;
@@ -0,0 +1,12 @@
+// Draws a 2 row rectangle and checks the first word of the first three screen rows
+
+load Rect.asm,
+output-file Rect.out,
+compare-to Rect.cmp,
+output-list RAM[0]%D2.6.2 RAM[16384]%X3.4.3 RAM[16416]%X3.4.3 RAM[16448]%B1.16.1;
+
+set RAM[0] 2;
+while PC <> 23 {
+  ticktock;
+}
+output;
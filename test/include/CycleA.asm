@@ -0,0 +1 @@
+.include "CycleB.asm"
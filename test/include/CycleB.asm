@@ -0,0 +1,2 @@
+    @0
+.include "CycleA.asm"
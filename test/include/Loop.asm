@@ -0,0 +1,3 @@
+(END)
+    @END
+    0;JMP
@@ -0,0 +1,8 @@
+// Includes a macro library twice; .once keeps the second copy out
+.include "Macros.asm"
+.include "Macros.asm"
+
+    SETD 7
+    @result
+    M=D
+.include "Loop.asm"
@@ -0,0 +1,6 @@
+    @result
+    D=M
+    @value
+    M=D
+    @0
+    AM=M+5
@@ -0,0 +1,7 @@
+// Macros shared between programs
+.once
+
+.macro SETD value
+    @%value
+    D=A
+.endm
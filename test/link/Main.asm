@@ -0,0 +1,29 @@
+// Multiplies 6 by 7 with the routine in Math.asm, and stores the product in result
+.extern mult
+.extern ret
+.global result
+
+    @count
+    M=1
+    @6
+    D=A
+    @R0
+    M=D
+    @7
+    D=A
+    @R1
+    M=D
+    @BACK
+    D=A
+    @ret
+    M=D
+    @mult
+    0;JMP
+(BACK)
+    @R2
+    D=M
+    @result
+    M=D
+(END)
+    @END
+    0;JMP
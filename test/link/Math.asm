@@ -0,0 +1,28 @@
+// R2 = R0 * R1, then returns to the ROM address stored in ret
+.global mult
+.global ret
+
+(mult)
+    @R2
+    M=0
+    @R1
+    D=M
+    @count
+    M=D
+(LOOP)
+    @count
+    D=M
+    @END
+    D;JEQ
+    @R0
+    D=M
+    @R2
+    M=D+M
+    @count
+    M=M-1
+    @LOOP
+    0;JMP
+(END)
+    @ret
+    A=M
+    0;JMP
@@ -0,0 +1,6 @@
+.extern missing
+.global mult
+
+(mult)
+    @missing
+    0;JMP
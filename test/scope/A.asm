@@ -0,0 +1,11 @@
+// Module A exports start and keeps its loop and counter private
+.global start
+
+(start)
+    @count
+    M=0
+(LOOP)
+    @count
+    M=M+1
+    @LOOP
+    0;JMP
@@ -0,0 +1,8 @@
+// Module B has its own LOOP and count, and jumps to A's start
+.extern start
+
+(LOOP)
+    @count
+    M=-1
+    @start
+    0;JMP
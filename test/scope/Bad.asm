@@ -0,0 +1,4 @@
+.extern finish
+
+    @LOOP
+    0;JMP
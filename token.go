@@ -1,8 +0,0 @@
-package main
-
-// Constants that signify special types of pseudo-command
-const (
-	CommentToken = "//"
-	ACmdToken    = "@"
-	LabelToken   = "("
-)